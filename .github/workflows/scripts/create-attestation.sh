@@ -4,44 +4,56 @@ set -euo pipefail
 
 source .github/workflows/scripts/common.sh
 
-time_verified=$(date)
-
-verification_result="FAILED"
-if [[ "${SUCCESS}" == "true" ]]; then
-    verification_result="PASSED"
-fi
-
-cat <<EOF | jq > vsa.json
-{
-    "verifier": {
-        "id": "${TRUSTED_VERIFIER}"
-    },
-    "time_verified": "${time_verified}",
-    "policy": {
-        "uri": "${UNTRUSTED_USER_POLICY}"
-    },
-    "verificationResult": "${verification_result}"
-}
-EOF
-
+# The VSA is generated from the evaluation result.
+# Untrusted values are passed as arguments and never interpreted.
+# The namespace and the labels are validated by the command.
+metadata_args=()
 if [[ -n "${UNTRUSTED_NAMESPACE:-}" ]]; then
-   jq <vsa.json ".metadata.namespace = \"${UNTRUSTED_NAMESPACE}\"" > tmp.json
-    mv tmp.json vsa.json
+    metadata_args+=(--namespace "${UNTRUSTED_NAMESPACE}")
 fi
-
 if [[ -n "${UNTRUSTED_LABELS:-}" ]]; then
-    # TODO: validate that UNTRUSTED_LABELS is a map.
-    jq <vsa.json ".metadata.labels = ${UNTRUSTED_LABELS}" > tmp.json
-    mv tmp.json vsa.json
+    metadata_args+=(--labels "${UNTRUSTED_LABELS}")
 fi
+./policy-verifier attest vsa \
+    "${metadata_args[@]}" \
+    --result policy-result.json \
+    --subject "${UNTRUSTED_IMAGE}@${UNTRUSTED_DIGEST}" \
+    --resource-uri "${UNTRUSTED_IMAGE}" \
+    --verifier-id "${TRUSTED_VERIFIER}" \
+    --policy "${UNTRUSTED_USER_POLICY_PATH}" \
+    --policy-uri "${UNTRUSTED_USER_POLICY}" \
+    --input-attestation provenance.json \
+    --output vsa.json
 
 jq <vsa.json
 
+# Only passing verifications are published: a failed or audited
+# evaluation fails the job instead.
+if [[ "$(jq -r '.predicate.verificationResult' vsa.json)" != "PASSED" ]]; then
+    echo "verification did not pass: the VSA is not published" >&2
+    exit 1
+fi
+
+jq <vsa.json '.predicate' > predicate.json
+
+# Without access to Fulcio and Rekor, the VSA is signed with a local key
+# and stored as a bundle.
+if [[ -n "${TRUSTED_SIGNING_KEY_PATH:-}" ]]; then
+    ./policy-verifier attest predicate \
+        --predicate-type https://slsa.dev/verification_summary/v1 \
+        --predicate predicate.json \
+        --subject "${UNTRUSTED_IMAGE}@${UNTRUSTED_DIGEST}" \
+        --key "${TRUSTED_SIGNING_KEY_PATH}" \
+        --output vsa.bundle.json
+    jq <vsa.bundle.json
+    exit 0
+fi
+
 # TODO: sign with cosign and store in file https://fig.io/manual/cosign/sign
 # WARNING: this does not include Rekor information.
 # https://github.com/sigstore/cosign/issues/3110
 # https://github.com/sigstore/cosign/pull/2994 
-cosign attest --yes --type https://slsa.dev/verification_summary/v1 --predicate vsa.json "${UNTRUSTED_IMAGE}@${UNTRUSTED_DIGEST}"
+cosign attest --yes --type https://slsa.dev/verification_summary/v1 --predicate predicate.json "${UNTRUSTED_IMAGE}@${UNTRUSTED_DIGEST}"
 
 # verify with:
 # cosign verify-attestation <image> \
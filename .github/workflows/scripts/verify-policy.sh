@@ -4,15 +4,25 @@ set -euo pipefail
 
 source .github/workflows/scripts/common.sh
 
-if [[ "${UNTRUSTED_BUILDER_ID}" == "" ]]; then
-    log_error "internal error: no builder ID"
-fi
-
 validate_path "${UNTRUSTED_USER_POLICY}"
 trusted_path="${UNTRUSTED_USER_POLICY}"
 
+# Exit codes: 0 pass, 1 fail, 2 invalid, 3 audit.
+# Audited violations do not block the evaluation, but their VSA
+# is not published.
+# The result is used to create the verification summary attestation.
+# The labels are matched by the label selectors of the policies.
+status=0
 ./policy-verifier eval \
+    --audit-exit-code 3 \
+    --labels "${UNTRUSTED_LABELS:-}" \
+    --output json \
     --files ".slsa/policy.json,${trusted_path}" \
-    --source-uri "${UNTRUSTED_REPOSITORY}" \
-    --image-uri "${UNTRUSTED_MUTABLE_IMAGE}" \
-    --builder-id "${UNTRUSTED_BUILDER_ID}"
+    --provenance provenance.json \
+    --image-uri "${UNTRUSTED_MUTABLE_IMAGE}@${UNTRUSTED_DIGEST}" > policy-result.json || status=$?
+
+cat policy-result.json
+
+if [[ "${status}" -ne 0 ]] && [[ "${status}" -ne 3 ]]; then
+    exit "${status}"
+fi
@@ -23,6 +23,7 @@ provenance=$(slsa-verifier verify-image "${IMMUTABLE_IMAGE}" \
             --print-provenance)
 
 if [[ "${provenance}" != "" ]]; then
+    echo "${provenance}" > provenance.json
     echo "builder_id=${builder_id}" >> "$GITHUB_OUTPUT"
     echo "source_uri=git+https://github.com/${GITHUB_REPOSITORY}" >> "$GITHUB_OUTPUT"
     exit 0
@@ -35,6 +36,7 @@ provenance=$(slsa-verifier verify-image "${IMMUTABLE_IMAGE}" \
             --print-provenance)
 
 if [[ "${provenance}" != "" ]]; then
+    echo "${provenance}" > provenance.json
     echo "builder_id=${builder_id}" >> "$GITHUB_OUTPUT"
     echo "source_uri=git+https://github.com/${GITHUB_REPOSITORY}" >> "$GITHUB_OUTPUT"
     exit 0
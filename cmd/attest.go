@@ -0,0 +1,258 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/store"
+	"github.com/laurentsimon/slsa-e2e/pkg/vsa"
+)
+
+var vsaResultPath string
+var vsaSubject string
+var vsaResourceURI string
+var vsaVerifierID string
+var vsaPolicyPath string
+var vsaPolicyURI string
+var vsaInputAttestations []string
+var vsaNamespace string
+var vsaLabels []string
+var vsaOutputPath string
+var vsaSigning signingOptions
+
+var predicateType string
+var predicatePath string
+var predicateSubjects []string
+var predicateSigning signingOptions
+var predicateOutputPath string
+
+// attestCmd represents the attest command
+var attestCmd = &cobra.Command{
+	Use:   "attest",
+	Short: "Create attestations",
+}
+
+// attestVsaCmd represents the attest vsa command
+var attestVsaCmd = &cobra.Command{
+	Use:   "vsa",
+	Short: "Create a Verification Summary Attestation from an evaluation result",
+	Long: `Create a Verification Summary Attestation from an evaluation result.
+
+The result is the output of 'eval --output json'. The attestation is an
+in-toto statement with a https://slsa.dev/verification_summary/v1 predicate.
+It is written to the output file. With --namespace and --labels, the
+predicate records the Kubernetes resource the image was verified for.
+
+With --key, the statement is signed with the private key and written
+as a Sigstore bundle, without a certificate or a transparency log entry.
+With --store, the bundle is also added to an attestation store.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(vsaResultPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read file: %v\n", err)
+			os.Exit(1)
+		}
+		var result results.Result
+		if err := json.Unmarshal(content, &result); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to unmarshal: %v\n", err)
+			os.Exit(1)
+		}
+
+		subject, err := intoto.SubjectFromImage(vsaSubject)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid subject: %v\n", err)
+			os.Exit(1)
+		}
+		policyContent, err := os.ReadFile(vsaPolicyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read file: %v\n", err)
+			os.Exit(1)
+		}
+		labelMap, err := parseLabels(vsaLabels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		summary := vsa.Summary{
+			Subject:      subject,
+			ResourceURI:  vsaResourceURI,
+			VerifierID:   vsaVerifierID,
+			Policy:       intoto.Descriptor(vsaPolicyURI, policyContent),
+			TimeVerified: time.Now(),
+			Namespace:    vsaNamespace,
+			Labels:       labelMap,
+		}
+		for _, path := range vsaInputAttestations {
+			attestation, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to read file: %v\n", err)
+				os.Exit(1)
+			}
+			summary.InputAttestations = append(summary.InputAttestations, intoto.Descriptor(path, attestation))
+		}
+
+		statement, err := vsa.New(summary, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create VSA: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeStatement(statement, vsaSigning, vsaOutputPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// attestPredicateCmd represents the attest predicate command
+var attestPredicateCmd = &cobra.Command{
+	Use:   "predicate",
+	Short: "Sign a predicate for one or more subjects",
+	Long: `Sign a predicate for one or more subjects.
+
+The predicate file is wrapped into an in-toto statement, e.g. a VSA,
+release or deployment predicate created by another tool. The statement
+is signed with the private key and written to the output file as a
+Sigstore bundle, without a certificate or a transparency log entry.
+With --store, the bundle is also added to an attestation store.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(predicatePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read file: %v\n", err)
+			os.Exit(1)
+		}
+		var predicate json.RawMessage
+		if err := json.Unmarshal(content, &predicate); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to unmarshal: %v\n", err)
+			os.Exit(1)
+		}
+		statement := intoto.Statement[json.RawMessage]{
+			Type:          intoto.StatementType,
+			PredicateType: predicateType,
+			Predicate:     predicate,
+		}
+		for _, s := range predicateSubjects {
+			subject, err := intoto.SubjectFromImage(s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid subject: %v\n", err)
+				os.Exit(1)
+			}
+			statement.Subject = append(statement.Subject, subject)
+		}
+		if err := writeStatement(statement, predicateSigning, predicateOutputPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// signingOptions are the flags to sign and store attestations.
+type signingOptions struct {
+	keyPath  string
+	storeRef string
+}
+
+func (o *signingOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.keyPath, "key", "", "A PEM ECDSA or Ed25519 private key to sign the attestation with")
+	cmd.Flags().StringVar(&o.storeRef, "store", "", "A store to add the signed attestation to, of the form dir:path or oci:path")
+}
+
+// writeStatement writes the statement to path. If a key is set, the
+// statement is signed with the key and written as a bundle. The bundle
+// is also added to the store, if any, for the digests of its subjects.
+func writeStatement(statement any, signing signingOptions, path string) error {
+	if signing.storeRef != "" && signing.keyPath == "" {
+		return errors.New("--store requires --key")
+	}
+	content, err := json.Marshal(statement)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	var output any = statement
+	if signing.keyPath != "" {
+		signer, err := dsse.LoadSigner(signing.keyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load key: %w", err)
+		}
+		envelope, err := dsse.Sign(signer, dsse.PayloadType, content)
+		if err != nil {
+			return err
+		}
+		output = dsse.NewBundle(*envelope, signer.KeyID())
+	}
+	attestation, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := os.WriteFile(path, attestation, 0o600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if signing.storeRef == "" {
+		return nil
+	}
+
+	s, err := store.Open(signing.storeRef)
+	if err != nil {
+		return err
+	}
+	var header intoto.Statement[json.RawMessage]
+	if err := json.Unmarshal(content, &header); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	for i := range header.Subject {
+		for alg, value := range header.Subject[i].Digest {
+			if err := s.Put(alg+":"+value, header.PredicateType, attestation); err != nil {
+				return fmt.Errorf("failed to store attestation: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(attestCmd)
+	attestCmd.AddCommand(attestVsaCmd)
+	attestCmd.AddCommand(attestPredicateCmd)
+
+	attestVsaCmd.Flags().StringVar(&vsaResultPath, "result", "", "The evaluation result, as output by 'eval --output json'")
+	attestVsaCmd.Flags().StringVar(&vsaSubject, "subject", "", "The verified image, of the form name@alg:digest")
+	attestVsaCmd.Flags().StringVar(&vsaResourceURI, "resource-uri", "", "The URI of the verified resource")
+	attestVsaCmd.Flags().StringVar(&vsaVerifierID, "verifier-id", "", "The identity of the verifier")
+	attestVsaCmd.Flags().StringVar(&vsaPolicyPath, "policy", "", "The policy file the image was verified against")
+	attestVsaCmd.Flags().StringVar(&vsaPolicyURI, "policy-uri", "", "The URI of the policy")
+	attestVsaCmd.Flags().StringSliceVar(&vsaInputAttestations, "input-attestation", []string{}, "The attestation files used during the verification")
+	attestVsaCmd.Flags().StringVar(&vsaNamespace, "namespace", "", "The Kubernetes namespace the image was verified for")
+	attestVsaCmd.Flags().StringSliceVar(&vsaLabels, "labels", []string{}, "A list of key=value labels of the Kubernetes resource the image was verified for")
+	attestVsaCmd.Flags().StringVarP(&vsaOutputPath, "output", "o", "vsa.json", "The file to write the attestation to")
+	vsaSigning.addFlags(attestVsaCmd)
+
+	attestVsaCmd.MarkFlagRequired("result")
+	attestVsaCmd.MarkFlagRequired("subject")
+	attestVsaCmd.MarkFlagRequired("resource-uri")
+	attestVsaCmd.MarkFlagRequired("verifier-id")
+	attestVsaCmd.MarkFlagRequired("policy")
+	attestVsaCmd.MarkFlagRequired("policy-uri")
+
+	attestPredicateCmd.Flags().StringVar(&predicateType, "predicate-type", "", "The type of the predicate")
+	attestPredicateCmd.Flags().StringVar(&predicatePath, "predicate", "", "The JSON predicate file")
+	attestPredicateCmd.Flags().StringSliceVar(&predicateSubjects, "subject", []string{}, "The subjects, of the form name@alg:digest")
+	predicateSigning.addFlags(attestPredicateCmd)
+	attestPredicateCmd.Flags().StringVarP(&predicateOutputPath, "output", "o", "attestation.json", "The file to write the bundle to")
+
+	attestPredicateCmd.MarkFlagRequired("predicate-type")
+	attestPredicateCmd.MarkFlagRequired("predicate")
+	attestPredicateCmd.MarkFlagRequired("subject")
+	attestPredicateCmd.MarkFlagRequired("key")
+}
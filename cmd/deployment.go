@@ -0,0 +1,164 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment"
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/attestation"
+)
+
+const githubOIDCIssuer = "https://token.actions.githubusercontent.com"
+
+var deploymentOutputPath string
+var deploymentSigning signingOptions
+
+// deploymentCmd represents the deployment command
+var deploymentCmd = &cobra.Command{
+	Use:   "deployment",
+	Short: "Evaluate deployment policies",
+}
+
+// deploymentEvaluateCmd represents the deployment evaluate command
+var deploymentEvaluateCmd = &cobra.Command{
+	Use:   "evaluate orgPath policiesDir image@digest policyID deployerID",
+	Short: "Evaluate the deployment policy for an image",
+	Long: `Evaluate the deployment policy for an image.
+
+The org policy defines the trusted releasers and, optionally, the
+deployers allowed to evaluate it. The policy ID is the path of the deployment policy file
+relative to the policies directory. The release attestations of the image
+are verified using cosign.`,
+	Args: cobra.ExactArgs(5),
+	Run: func(cmd *cobra.Command, args []string) {
+		orgPath, policiesDir, imageURI, policyID, deployerID := args[0], args[1], args[2], args[3], args[4]
+
+		pol, err := deployment.FromFiles(orgPath, policiesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create policy: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := pol.Evaluate(imageURI, policyID, deployerID, cosignVerifier{})
+		if !result.Pass() {
+			fmt.Fprintf(os.Stderr, "failed to verify: %v\n", result)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", result)
+	},
+}
+
+// deploymentAttestCmd represents the deployment attest command
+var deploymentAttestCmd = &cobra.Command{
+	Use:   "attest orgPath policiesDir image@digest policyID deployerID",
+	Short: "Create the deployment attestation for an image",
+	Long: `Create the deployment attestation for an image.
+
+The deployment policy is evaluated as with 'deployment evaluate'. If the
+deployment passes, an in-toto statement with a https://slsa.dev/deployment/v0.1
+predicate is written to the output file. It records the principal, the
+package, the release attestation and the policy files. With --key, it is
+signed with the private key and written as a Sigstore bundle, which --store
+adds to an attestation store.`,
+	Args: cobra.ExactArgs(5),
+	Run: func(cmd *cobra.Command, args []string) {
+		orgPath, policiesDir, imageURI, policyID, deployerID := args[0], args[1], args[2], args[3], args[4]
+
+		pol, err := deployment.FromFiles(orgPath, policiesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create policy: %v\n", err)
+			os.Exit(1)
+		}
+
+		statement, result := pol.Attest(imageURI, policyID, deployerID, cosignVerifier{}, time.Now())
+		if !result.Pass() {
+			fmt.Fprintf(os.Stderr, "failed to verify: %v\n", result)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", result)
+
+		if err := writeStatement(statement, deploymentSigning, deploymentOutputPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// cosignVerifier verifies release attestations by calling cosign.
+type cosignVerifier struct{}
+
+func (cosignVerifier) VerifyReleaseAttestation(digest, imageName, releaserID string) ([]options.ReleaseAttestation, error) {
+	var stdout bytes.Buffer
+	c := exec.Command("cosign", "verify-attestation", imageName+"@"+digest,
+		"--certificate-oidc-issuer", githubOIDCIssuer,
+		"--certificate-identity", releaserID,
+		"--type", attestation.PredicateType)
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("cosign: %w", err)
+	}
+
+	// cosign outputs one DSSE envelope per line.
+	var attestations []options.ReleaseAttestation
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var envelope struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+		var statement attestation.Statement
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		if statement.PredicateType != attestation.PredicateType {
+			continue
+		}
+		attestations = append(attestations, options.ReleaseAttestation{
+			Environment: statement.Predicate.Package.Environment,
+			BuildLevel:  statement.Predicate.Build.SlsaLevel,
+			Subjects:    statement.Subject,
+			Descriptor:  intoto.Descriptor(attestationLocation(imageName, digest), payload),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	return attestations, nil
+}
+
+// attestationLocation returns the location of the attestations of
+// an image in its registry, where cosign stores them.
+func attestationLocation(imageName, digest string) string {
+	return imageName + ":" + strings.Replace(digest, ":", "-", 1) + ".att"
+}
+
+func init() {
+	rootCmd.AddCommand(deploymentCmd)
+	deploymentCmd.AddCommand(deploymentEvaluateCmd)
+	deploymentCmd.AddCommand(deploymentAttestCmd)
+
+	deploymentAttestCmd.Flags().StringVarP(&deploymentOutputPath, "output", "o", "deployment.json", "The file to write the attestation to")
+	deploymentSigning.addFlags(deploymentAttestCmd)
+}
@@ -4,12 +4,35 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
 	"github.com/laurentsimon/slsa-e2e/pkg/policy"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/sarif"
+	"github.com/laurentsimon/slsa-e2e/pkg/provenance"
+	"github.com/laurentsimon/slsa-e2e/pkg/store"
+)
+
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputSARIF = "sarif"
+)
+
+// Exit codes of the eval command. Audited results exit with
+// exitPass unless --audit-exit-code is set.
+const (
+	exitPass    = 0
+	exitFail    = 1
+	exitInvalid = 2
 )
 
 var labels []string
@@ -17,42 +40,230 @@ var files []string
 var sourceURI string
 var imageURI string
 var builderID string
+var sourceAttestorID string
+var sourceLevel int
+var evalEnvironment string
+var output string
+var provenancePath string
+var evalStoreRef string
+var auditExitCode int
 
 // evalCmd represents the eval command
 var evalCmd = &cobra.Command{
 	Use:   "eval",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
+	Short: "Evaluate a policy hierarchy for an image",
+	Long: `Evaluate a policy hierarchy for an image.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("eval called")
-		fmt.Println("labels:", labels)
-		fmt.Println("files:", files)
+With --output json or sarif, the result is written to stdout.
+With --output text, it is written to stderr.
+
+The source URI may be given as git+https://host/org/repo, https://host/org/repo,
+or host/org/repo, with an optional .git suffix and an optional
+@commit or @refs/... ref. Only branches (@refs/heads/branch) and tags
+(@refs/tags/tag) are allowed by the refs of the policies. Sources are
+matched in their canonical form: the repository and the ref are matched
+separately.
 
+With --provenance, the source URI and the builder ID are read from
+the SLSA provenance of the image instead of --source-uri and --builder-id.
+If the org policy declares trust roots, the provenance must be a DSSE
+envelope or a Sigstore bundle signed by one of them, and its builder
+must be one of the builder_ids of that root. The image must then
+be of the form image@sha256:digest, and the digest must be a subject of
+the provenance. With --store, the provenance is looked up in an
+attestation store by the digest of the image.
+
+Policy uri and id fields may be globs, or predicates given as
+{"regex": "..."} or {"cel": "..."}. CEL expressions may refer to
+source, image, builder, attestor, labels and, with --environment,
+environment.
+
+The exit code is 0 if the policy passes, 1 if it fails, and 2 if
+the policy or the inputs are invalid. If the violations are only
+audited, the result is reported as AUDIT and the exit code is 0,
+so that audit mode does not block; set --audit-exit-code, e.g. to 3,
+to tell audited results apart.`,
+	Args: cobra.NoArgs,
+	// Errors are written by Execute, which exits with exitInvalid.
+	SilenceErrors: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if output != outputText && output != outputJSON && output != outputSARIF {
+			fmt.Fprintf(os.Stderr, "invalid output %q\n", output)
+			os.Exit(exitInvalid)
+		}
+		if auditExitCode < 0 || auditExitCode > 255 {
+			fmt.Fprintf(os.Stderr, "invalid audit exit code %d\n", auditExitCode)
+			os.Exit(exitInvalid)
+		}
 		if len(files) == 0 {
-			fmt.Fprintf(os.Stderr, "no files provided\n")
-			os.Exit(1)
+			writeResult(results.VerificationInvalid(errors.New("no files provided")))
 		}
 
 		pol, err := policy.FromFiles(files)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to create policy: %v\n", err)
-			os.Exit(1)
+			writeResult(results.VerificationInvalid(fmt.Errorf("failed to create policy: %w", err)))
 		}
 
-		result := pol.Evaluate(sourceURI, imageURI, builderID)
-		if result.Fail() {
-			fmt.Fprintf(os.Stderr, "failed to verify: %v\n", result)
-			os.Exit(1)
+		var subjects []intoto.ResourceDescriptor
+		if provenancePath != "" || evalStoreRef != "" {
+			if sourceURI != "" || builderID != "" {
+				writeResult(results.VerificationInvalid(errors.New("--provenance and --store cannot be used with --source-uri or --builder-id")))
+			}
+			prov, err := loadProvenance(pol)
+			if err != nil {
+				writeResult(results.VerificationInvalid(err))
+			}
+			sourceURI, builderID = prov.SourceURI, prov.BuilderID
+			// The ref is matched separately from the repository.
+			if prov.Ref != "" {
+				sourceURI += "@" + prov.Ref
+			}
+			subjects = prov.Subjects
 		}
-		fmt.Fprintf(os.Stderr, "%v\n", result)
+		if sourceURI == "" || builderID == "" {
+			writeResult(results.VerificationInvalid(errors.New("--source-uri and --builder-id, or --provenance, are required")))
+		}
+
+		labelMap, err := parseLabels(labels)
+		if err != nil {
+			writeResult(results.VerificationInvalid(err))
+		}
+
+		opts := []options.Option{options.WithLabels(labelMap)}
+		if sourceAttestorID != "" {
+			opts = append(opts, options.WithSourceAttestation(sourceAttestorID))
+		}
+		if evalEnvironment != "" {
+			opts = append(opts, options.WithEnvironment(evalEnvironment))
+		}
+		if cmd.Flags().Changed("source-level") {
+			opts = append(opts, options.WithSourceLevel(sourceLevel))
+		}
+		if subjects != nil {
+			opts = append(opts, options.WithSubjects(subjects))
+		}
+
+		writeResult(pol.Evaluate(sourceURI, imageURI, builderID, opts...))
 	},
 }
 
+// loadProvenance reads the provenance from the --provenance file, or
+// looks it up in the --store by the digest of the image. The provenance
+// is verified offline if the org declares trust roots.
+func loadProvenance(pol *policy.Policy) (*provenance.Provenance, error) {
+	if provenancePath != "" {
+		if evalStoreRef != "" {
+			return nil, errors.New("--provenance cannot be used with --store")
+		}
+		content, err := os.ReadFile(provenancePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return verifyProvenance(pol, content)
+	}
+
+	s, err := store.Open(evalStoreRef)
+	if err != nil {
+		return nil, err
+	}
+	_, digest, ok := strings.Cut(imageURI, "@")
+	if !ok {
+		return nil, fmt.Errorf("image %q: no digest", imageURI)
+	}
+	var errs []error
+	for _, predicateType := range []string{provenance.PredicateTypeV1, provenance.PredicateTypeV02} {
+		attestations, err := s.Get(digest, predicateType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get provenance: %w", err)
+		}
+		for _, content := range attestations {
+			prov, err := verifyProvenance(pol, content)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			return prov, nil
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no provenance for %q in the store", digest)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func verifyProvenance(pol *policy.Policy, content []byte) (*provenance.Provenance, error) {
+	payload, err := pol.VerifyAttestation(content)
+	switch {
+	case errors.Is(err, policy.ErrNoTrustRoots):
+		payload = content
+	case err != nil:
+		return nil, fmt.Errorf("failed to verify provenance: %w", err)
+	}
+	prov, err := provenance.FromBytes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance: %w", err)
+	}
+	return prov, nil
+}
+
+// parseLabels parses labels of the form key=value.
+func parseLabels(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(labels))
+	for _, label := range labels {
+		key, value, ok := strings.Cut(strings.TrimSpace(label), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", label)
+		}
+		if _, exists := parsed[key]; exists {
+			return nil, fmt.Errorf("duplicate label %q", key)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// writeResult writes the result in the requested output format
+// and exits with the code of the result.
+func writeResult(result results.Verification) {
+	switch output {
+	case outputJSON:
+		content, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+		fmt.Println(string(content))
+	case outputSARIF:
+		log, err := sarif.FromResult(result.Result())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to convert to SARIF: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+		content, err := json.MarshalIndent(log, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal: %v\n", err)
+			os.Exit(exitInvalid)
+		}
+		fmt.Println(string(content))
+	default:
+		fmt.Fprintf(os.Stderr, "%v\n", result)
+	}
+	os.Exit(exitCode(result))
+}
+
+func exitCode(result results.Verification) int {
+	switch {
+	case result.Pass():
+		return exitPass
+	case result.Audit():
+		return auditExitCode
+	case result.Fail():
+		return exitFail
+	default:
+		return exitInvalid
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(evalCmd)
 
@@ -65,14 +276,19 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 
-	evalCmd.Flags().StringSliceVarP(&labels, "labels", "l", []string{}, "A list of labels")
-	evalCmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "A list of orddered files")
-	evalCmd.Flags().StringVarP(&sourceURI, "source-uri", "s", "", "The source-uri")
+	evalCmd.Flags().StringSliceVarP(&labels, "labels", "l", []string{}, "A list of key=value labels, matched by the label selectors of the policies")
+	evalCmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "An ordered list of policy files, from the org to the repo")
+	evalCmd.Flags().StringVarP(&sourceURI, "source-uri", "s", "", "The source-uri, with an optional @ref")
 	evalCmd.Flags().StringVarP(&imageURI, "image-uri", "i", "", "The image-uri")
 	evalCmd.Flags().StringVarP(&builderID, "builder-id", "b", "", "The builder ID")
+	evalCmd.Flags().StringVar(&sourceAttestorID, "source-attestor-id", "", "The ID of the source attestation verifier")
+	evalCmd.Flags().IntVar(&sourceLevel, "source-level", 0, "The attested source level")
+	evalCmd.Flags().StringVar(&evalEnvironment, "environment", "", "The environment the image is evaluated for, available to CEL predicates")
+	evalCmd.Flags().StringVarP(&output, "output", "o", outputText, "The output format: text, json or sarif")
+	evalCmd.Flags().StringVar(&provenancePath, "provenance", "", "A SLSA provenance file, as an in-toto statement or a DSSE envelope")
+	evalCmd.Flags().StringVar(&evalStoreRef, "store", "", "A store to look up the SLSA provenance in, of the form dir:path or oci:path")
+	evalCmd.Flags().IntVar(&auditExitCode, "audit-exit-code", exitPass, "The exit code if the violations are only audited")
 
 	evalCmd.MarkFlagRequired("files")
-	evalCmd.MarkFlagRequired("source-uri")
 	evalCmd.MarkFlagRequired("image-uri")
-	evalCmd.MarkFlagRequired("builder-id")
 }
@@ -0,0 +1,54 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/imageref"
+)
+
+var imageRegistryOnly bool
+
+// imageCmd represents the image command
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Inspect image references",
+}
+
+// imageNormalizeCmd represents the image normalize command
+var imageNormalizeCmd = &cobra.Command{
+	Use:   "normalize image",
+	Short: "Print the canonical form of an image reference",
+	Long: `Print the canonical form of an image reference.
+
+The default registry and the library/ namespace of official images are
+added, the docker:// scheme is removed and the registry is lowercased:
+nginx, docker://nginx and docker.io/library/nginx are all printed as
+docker.io/library/nginx. This is the form images are matched in by the
+policies. With --registry, only the registry is printed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ref, err := imageref.Parse(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if imageRegistryOnly {
+			fmt.Println(ref.Registry)
+			return
+		}
+		fmt.Println(ref)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+	imageCmd.AddCommand(imageNormalizeCmd)
+
+	imageNormalizeCmd.Flags().BoolVar(&imageRegistryOnly, "registry", false, "Print only the registry of the image")
+}
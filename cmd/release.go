@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/release"
+	"github.com/laurentsimon/slsa-e2e/pkg/sourceuri"
+)
+
+var releaseAttestationPath string
+var releaseSigning signingOptions
+
+// releaseCmd represents the release command
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Evaluate release policies",
+}
+
+// releaseEvaluateCmd represents the release evaluate command
+var releaseEvaluateCmd = &cobra.Command{
+	Use:   "evaluate orgPath projectsDir image@digest releaserID [environment]",
+	Short: "Evaluate the release policy for an image",
+	Long: `Evaluate the release policy for an image.
+
+The org policy defines the trusted builders. The projects directory contains
+one policy file per package; the org policy file is ignored if it lives in it.
+The build provenance of the image is verified using slsa-verifier.
+
+With --attestation, a release attestation with a https://slsa.dev/release/v0.1
+predicate is written to the file if the release passes. With --key, it is
+signed with the private key and written as a Sigstore bundle, which --store
+adds to an attestation store.`,
+	Args: cobra.RangeArgs(4, 5),
+	Run: func(cmd *cobra.Command, args []string) {
+		orgPath, projectsDir, imageURI, releaserID := args[0], args[1], args[2], args[3]
+		var environment string
+		if len(args) == 5 {
+			environment = args[4]
+		}
+
+		pol, err := release.FromFiles(orgPath, projectsDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create policy: %v\n", err)
+			os.Exit(1)
+		}
+
+		statement, result := pol.Attest(imageURI, releaserID, environment, slsaVerifier{}, time.Now())
+		if !result.Pass() {
+			fmt.Fprintf(os.Stderr, "failed to verify: %v\n", result)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", result)
+
+		if releaseAttestationPath != "" {
+			if err := writeStatement(statement, releaseSigning, releaseAttestationPath); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// slsaVerifier verifies build provenance by calling slsa-verifier.
+type slsaVerifier struct{}
+
+func (slsaVerifier) VerifyBuildAttestation(digest, imageName, builderID, sourceURI string) error {
+	source, err := sourceuri.Parse(sourceURI)
+	if err != nil {
+		return err
+	}
+	// slsa-verifier takes the repository without a scheme, and its ref apart.
+	args := []string{"verify-image", imageName + "@" + digest,
+		"--source-uri", source.Location(),
+		"--builder-id", builderID}
+	if branch := source.Branch(); branch != "" {
+		args = append(args, "--source-branch", branch)
+	}
+	if tag := source.Tag(); tag != "" {
+		args = append(args, "--source-tag", tag)
+	}
+	c := exec.Command("slsa-verifier", args...)
+	c.Stdout = os.Stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("slsa-verifier: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseEvaluateCmd)
+
+	releaseEvaluateCmd.Flags().StringVar(&releaseAttestationPath, "attestation", "", "The file to write the release attestation to")
+	releaseSigning.addFlags(releaseEvaluateCmd)
+}
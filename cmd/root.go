@@ -4,6 +4,7 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -27,8 +28,13 @@ to quickly create a Cobra application.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
 	if err != nil {
+		// Invalid flags and arguments of eval are invalid inputs.
+		if cmd == evalCmd {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInvalid)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,140 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/admission"
+	"github.com/laurentsimon/slsa-e2e/pkg/store"
+)
+
+const admissionPath = "/validate"
+
+var admissionPolicyPath string
+var admissionAddr string
+var admissionCertPath string
+var admissionKeyPath string
+var admissionStoreRef string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the policy engine",
+}
+
+// serveAdmissionCmd represents the serve admission command
+var serveAdmissionCmd = &cobra.Command{
+	Use:   "admission",
+	Short: "Serve a Kubernetes validating admission webhook",
+	Long: `Serve a Kubernetes validating admission webhook over HTTPS.
+
+The webhook reviews the Pods, Deployments, Jobs and CronJobs sent to
+` + admissionPath + `. Every container image must be pinned by digest and have a
+deployment attestation or a VSA required by the first rule of the admission
+policy that selects the namespace, the labels and the service account of
+its pods. The pods must run as the service account of the principal, and
+the VSA must be for their namespace and labels. Workloads that violate
+an audited rule are admitted with warnings.
+
+The attestations are downloaded with cosign, or looked up in the
+attestation store set by --store. They are verified with the trust
+roots of the admission policy, and must be signed by the principal_signers
+or the vsa.signers of the rule.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		policy, err := admission.PolicyFromFile(admissionPolicyPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create policy: %v\n", err)
+			os.Exit(1)
+		}
+
+		var attestations admission.Store = cosignStore{}
+		if admissionStoreRef != "" {
+			s, err := store.Open(admissionStoreRef)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open store: %v\n", err)
+				os.Exit(1)
+			}
+			attestations = digestStore{store: s}
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle(admissionPath, admission.NewWebhook(policy, attestations))
+		server := &http.Server{
+			Addr:              admissionAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		fmt.Fprintf(os.Stderr, "serving admission reviews on %s%s\n", admissionAddr, admissionPath)
+		if err := server.ListenAndServeTLS(admissionCertPath, admissionKeyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to serve: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// cosignStore downloads attestations by calling cosign.
+type cosignStore struct{}
+
+func (cosignStore) Get(imageURI, predicateType string) ([][]byte, error) {
+	var stdout bytes.Buffer
+	c := exec.Command("cosign", "download", "attestation", imageURI,
+		"--predicate-type", predicateType)
+	c.Stdout = &stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("cosign: %w", err)
+	}
+
+	// cosign outputs one DSSE envelope per line.
+	var attestations [][]byte
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		attestations = append(attestations, bytes.Clone(scanner.Bytes()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	return attestations, nil
+}
+
+// digestStore looks up the attestations of images in a store
+// by their digest.
+type digestStore struct {
+	store store.AttestationStore
+}
+
+func (s digestStore) Get(imageURI, predicateType string) ([][]byte, error) {
+	_, digest, ok := strings.Cut(imageURI, "@")
+	if !ok {
+		return nil, fmt.Errorf("image %q: no digest", imageURI)
+	}
+	return s.store.Get(digest, predicateType)
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.AddCommand(serveAdmissionCmd)
+
+	serveAdmissionCmd.Flags().StringVar(&admissionPolicyPath, "policy", "", "The admission policy file")
+	serveAdmissionCmd.Flags().StringVar(&admissionAddr, "addr", ":8443", "The address to listen on")
+	serveAdmissionCmd.Flags().StringVar(&admissionCertPath, "tls-cert", "", "The PEM certificate of the webhook")
+	serveAdmissionCmd.Flags().StringVar(&admissionKeyPath, "tls-key", "", "The PEM private key of the webhook")
+	serveAdmissionCmd.Flags().StringVar(&admissionStoreRef, "store", "", "A store to look up the attestations in, of the form dir:path or oci:path")
+
+	serveAdmissionCmd.MarkFlagRequired("policy")
+	serveAdmissionCmd.MarkFlagRequired("tls-cert")
+	serveAdmissionCmd.MarkFlagRequired("tls-key")
+}
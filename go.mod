@@ -3,6 +3,7 @@ module github.com/laurentsimon/slsa-e2e
 go 1.20
 
 require (
+	github.com/google/cel-go v0.17.8
 	github.com/google/go-cmp v0.5.9
 	github.com/ossf/scorecard/v4 v4.12.0
 	github.com/spf13/cobra v1.7.0
@@ -10,7 +11,13 @@ require (
 )
 
 require (
+	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230305170008-8188dc5388df // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/stoewer/go-strcase v1.2.0 // indirect
+	golang.org/x/text v0.11.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230731193218-e0aa005b6bdf // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230731193218-e0aa005b6bdf // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,191 @@
+package admission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+)
+
+// Modes of a rule.
+const (
+	// ModeEnforce denies the workloads that violate the rule.
+	ModeEnforce = "enforce"
+	// ModeAudit admits the workloads that violate the rule with a warning.
+	ModeAudit = "audit"
+)
+
+// principalScheme is the scheme of the principals of Kubernetes
+// service accounts, e.g. k8_sa://name@project.iam.gserviceaccount.com.
+const principalScheme = "k8_sa://"
+
+type Trust struct {
+	Roots []dsse.TrustRoot `json:"roots"`
+}
+
+// VSA accepts the images verified by a verifier at a minimum level.
+type VSA struct {
+	VerifierID       string `json:"verifier_id"`
+	RequireSlsaLevel int    `json:"require_slsa_level"`
+	// Signers are the IDs of the trust roots that may sign the VSAs.
+	Signers []string `json:"signers"`
+}
+
+// Rule defines the attestations required for the workloads
+// it selects. Empty selectors select every workload.
+type Rule struct {
+	Namespaces      []string          `json:"namespaces"`
+	Labels          map[string]string `json:"labels"`
+	ServiceAccounts []string          `json:"service_accounts"`
+	// Principal is the principal URI of the deployment attestations.
+	// Its service account must be the one of the pods.
+	Principal string `json:"principal"`
+	// PrincipalSigners are the IDs of the trust roots that may sign
+	// the deployment attestations.
+	PrincipalSigners []string `json:"principal_signers"`
+	VSA              *VSA     `json:"vsa"`
+	Mode             string   `json:"mode"`
+}
+
+// Policy is the admission policy. The first rule that selects
+// a workload applies. Workloads that no rule selects are denied.
+type Policy struct {
+	Format int    `json:"format"`
+	Trust  Trust  `json:"trust"`
+	Rules  []Rule `json:"rules"`
+
+	verifier *dsse.Verifier
+}
+
+// PolicyFromBytes creates an admission policy.
+func PolicyFromBytes(content []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(content, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if p.Format != 1 {
+		return nil, fmt.Errorf("invalid %q", "format")
+	}
+	verifier, err := dsse.NewVerifier(p.Trust.Roots)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %q: %w", "trust.roots", err)
+	}
+	p.verifier = verifier
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("empty %q", "rules")
+	}
+	rootIDs := make(map[string]bool, len(p.Trust.Roots))
+	for i := range p.Trust.Roots {
+		rootIDs[p.Trust.Roots[i].ID] = true
+	}
+	for i := range p.Rules {
+		if err := validateRule(&p.Rules[i], rootIDs); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	return &p, nil
+}
+
+// PolicyFromFile reads an admission policy file.
+func PolicyFromFile(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return PolicyFromBytes(content)
+}
+
+func validateRule(r *Rule, rootIDs map[string]bool) error {
+	if r.Principal == "" && r.VSA == nil {
+		return errors.New("no principal or vsa")
+	}
+	if r.Principal != "" {
+		if _, ok := principalServiceAccount(r.Principal); !ok {
+			return fmt.Errorf("invalid %q: %q", "principal", r.Principal)
+		}
+		if err := validateSigners("principal_signers", r.PrincipalSigners, rootIDs); err != nil {
+			return err
+		}
+	}
+	if r.VSA != nil {
+		if r.VSA.VerifierID == "" {
+			return fmt.Errorf("empty %q", "vsa.verifier_id")
+		}
+		if r.VSA.RequireSlsaLevel < 0 || r.VSA.RequireSlsaLevel > 4 {
+			return fmt.Errorf("invalid %q: %d", "vsa.require_slsa_level", r.VSA.RequireSlsaLevel)
+		}
+		if err := validateSigners("vsa.signers", r.VSA.Signers, rootIDs); err != nil {
+			return err
+		}
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = ModeEnforce
+	case ModeEnforce, ModeAudit:
+	default:
+		return fmt.Errorf("invalid %q: %q", "mode", r.Mode)
+	}
+	return nil
+}
+
+// validateSigners validates that the signers are trust roots.
+func validateSigners(field string, signers []string, rootIDs map[string]bool) error {
+	if len(signers) == 0 {
+		return fmt.Errorf("empty %q", field)
+	}
+	for _, signer := range signers {
+		if !rootIDs[signer] {
+			return fmt.Errorf("invalid %q: no trust root %q", field, signer)
+		}
+	}
+	return nil
+}
+
+// principalServiceAccount returns the name of the service account
+// of a principal, e.g. name for k8_sa://name@project.iam.gserviceaccount.com.
+func principalServiceAccount(principal string) (string, bool) {
+	account, ok := strings.CutPrefix(principal, principalScheme)
+	if !ok {
+		return "", false
+	}
+	name, _, _ := strings.Cut(account, "@")
+	return name, name != ""
+}
+
+// rule returns the first rule that selects the workload.
+func (p *Policy) rule(w *workload) *Rule {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.selects(w) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *Rule) selects(w *workload) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, w.namespace) {
+		return false
+	}
+	if len(r.ServiceAccounts) > 0 && !contains(r.ServiceAccounts, w.serviceAccount) {
+		return false
+	}
+	for k, v := range r.Labels {
+		if value, ok := w.labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
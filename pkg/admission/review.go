@@ -0,0 +1,45 @@
+package admission
+
+import "encoding/json"
+
+// ReviewAPIVersion is the API version of the admission reviews
+// the webhook accepts.
+const ReviewAPIVersion = "admission.k8s.io/v1"
+
+// Review is an AdmissionReview. Only the fields the webhook
+// uses are defined.
+type Review struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Request    *Request  `json:"request,omitempty"`
+	Response   *Response `json:"response,omitempty"`
+}
+
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// Request is the admission request of a review.
+type Request struct {
+	UID       string           `json:"uid"`
+	Kind      GroupVersionKind `json:"kind"`
+	Namespace string           `json:"namespace"`
+	Operation string           `json:"operation"`
+	Object    json.RawMessage  `json:"object"`
+}
+
+// Status is the reason of a denied request.
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Response is the admission response of a review.
+type Response struct {
+	UID      string   `json:"uid"`
+	Allowed  bool     `json:"allowed"`
+	Result   *Status  `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
@@ -0,0 +1,258 @@
+// Package admission implements a Kubernetes validating admission webhook
+// that admits the workloads whose images have the attestations required
+// by an admission policy.
+package admission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/attestation"
+	"github.com/laurentsimon/slsa-e2e/pkg/imageref"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/vsa"
+)
+
+// digestAlgorithm is the digest algorithm required to pin images.
+const digestAlgorithm = "sha256"
+
+const verifiedLevelPrefix = "SLSA_BUILD_LEVEL_"
+
+// Store looks up the attestations of images.
+type Store interface {
+	// Get returns the attestations with the predicate type for the image
+	// of the form name@alg:digest. They are verified by the caller.
+	Get(imageURI, predicateType string) ([][]byte, error)
+}
+
+// Webhook reviews admission requests.
+type Webhook struct {
+	policy *Policy
+	store  Store
+}
+
+// NewWebhook creates a webhook that looks up attestations in store.
+func NewWebhook(policy *Policy, store Store) *Webhook {
+	return &Webhook{
+		policy: policy,
+		store:  store,
+	}
+}
+
+// ServeHTTP serves admission reviews.
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var review Review
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.APIVersion != ReviewAPIVersion || review.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+	content, err := json.Marshal(Review{
+		APIVersion: review.APIVersion,
+		Kind:       review.Kind,
+		Response:   wh.Review(review.Request),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(content)
+}
+
+// Review returns the response to an admission request. Workloads
+// that violate an audited rule are admitted with warnings.
+func (wh *Webhook) Review(req *Request) *Response {
+	if (req.Operation != "CREATE" && req.Operation != "UPDATE") || !supported(req.Kind) {
+		return allow(req)
+	}
+	w, err := workloadFromRequest(req)
+	if err != nil {
+		return deny(req, http.StatusBadRequest, err.Error())
+	}
+	rule := wh.policy.rule(w)
+	if rule == nil {
+		return deny(req, http.StatusForbidden, fmt.Sprintf("no rule for namespace %q and service account %q",
+			w.namespace, w.serviceAccount))
+	}
+
+	var violations []string
+	for _, image := range w.images {
+		if err := wh.verifyImage(rule, w, image); err != nil {
+			violations = append(violations, err.Error())
+		}
+	}
+	switch {
+	case len(violations) == 0:
+		return allow(req)
+	case rule.Mode == ModeAudit:
+		resp := allow(req)
+		resp.Warnings = violations
+		return resp
+	default:
+		return deny(req, http.StatusForbidden, strings.Join(violations, "; "))
+	}
+}
+
+func allow(req *Request) *Response {
+	return &Response{UID: req.UID, Allowed: true}
+}
+
+func deny(req *Request, code int, message string) *Response {
+	return &Response{
+		UID:    req.UID,
+		Result: &Status{Code: code, Message: message},
+	}
+}
+
+// verifyImage verifies that the image has a deployment attestation
+// for the principal of the rule, or a VSA from its verifier for the
+// namespace and the labels of the workload.
+func (wh *Webhook) verifyImage(rule *Rule, w *workload, image string) error {
+	subject, err := intoto.SubjectFromImage(image)
+	if err != nil {
+		return err
+	}
+	if _, ok := subject.Digest[digestAlgorithm]; !ok {
+		return fmt.Errorf("image %q: digest must be %s", image, digestAlgorithm)
+	}
+	// Attestations are matched by the canonical name of the image.
+	ref, err := imageref.Parse(image)
+	if err != nil {
+		return err
+	}
+	subject.Name = ref.Name()
+
+	var errs []error
+	if rule.Principal != "" {
+		err := wh.verifyDeployment(image, subject, w, rule.Principal, rule.PrincipalSigners)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if rule.VSA != nil {
+		err := wh.verifyVSA(image, subject, w, *rule.VSA)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("image %q: %w", image, errors.Join(errs...))
+}
+
+func (wh *Webhook) verifyDeployment(image string, subject intoto.ResourceDescriptor, w *workload, principal string, signers []string) error {
+	// The attestation is for the principal, which must run the pods.
+	if account, _ := principalServiceAccount(principal); account != w.serviceAccount {
+		return fmt.Errorf("principal %q is not service account %q", principal, w.serviceAccount)
+	}
+	statements, err := wh.statements(image, subject, attestation.PredicateType, signers)
+	if err != nil {
+		return err
+	}
+	for _, payload := range statements {
+		var statement attestation.Statement
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			continue
+		}
+		if sameImage(statement.Predicate.Package.Name, subject.Name) &&
+			statement.Predicate.Principal.URI == principal {
+			return nil
+		}
+	}
+	return fmt.Errorf("no deployment attestation for principal %q", principal)
+}
+
+func (wh *Webhook) verifyVSA(image string, subject intoto.ResourceDescriptor, w *workload, policy VSA) error {
+	statements, err := wh.statements(image, subject, vsa.PredicateType, policy.Signers)
+	if err != nil {
+		return err
+	}
+	for _, payload := range statements {
+		var statement vsa.Statement
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			continue
+		}
+		if statement.Predicate.Verifier.ID == policy.VerifierID &&
+			sameImage(statement.Predicate.ResourceURI, subject.Name) &&
+			statement.Predicate.VerificationResult == vsa.ResultPassed &&
+			verifiedLevel(statement.Predicate.VerifiedLevels) >= policy.RequireSlsaLevel &&
+			verifiedFor(statement.Predicate.Metadata, w) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no VSA from verifier %q at level %d for namespace %q", policy.VerifierID, policy.RequireSlsaLevel, w.namespace)
+}
+
+// sameImage returns true if the attested image has the canonical
+// name. The tag of the attested image, if any, is ignored.
+func sameImage(attested, name string) bool {
+	ref, err := imageref.Parse(attested)
+	return err == nil && ref.Name() == name
+}
+
+// verifiedFor returns true if the VSA was verified for the namespace of
+// the workload, and if the workload has the labels it was verified for.
+func verifiedFor(metadata *vsa.Metadata, w *workload) bool {
+	if metadata == nil || metadata.Namespace != w.namespace {
+		return false
+	}
+	for k, v := range metadata.Labels {
+		if value, ok := w.labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// statements returns the statements with the predicate type whose
+// subjects have the digest of the image, signed by one of the signers.
+func (wh *Webhook) statements(image string, subject intoto.ResourceDescriptor, predicateType string, signers []string) ([][]byte, error) {
+	attestations, err := wh.store.Get(image, predicateType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attestations: %w", err)
+	}
+	var statements [][]byte
+	for _, content := range attestations {
+		payload, rootID, err := wh.policy.verifier.Verify(content)
+		if err != nil || !contains(signers, rootID) {
+			continue
+		}
+		var statement intoto.Statement[json.RawMessage]
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			continue
+		}
+		if statement.PredicateType != predicateType ||
+			!intoto.HasDigest(statement.Subject, digestAlgorithm, subject.Digest[digestAlgorithm]) {
+			continue
+		}
+		statements = append(statements, payload)
+	}
+	return statements, nil
+}
+
+// verifiedLevel returns the highest SLSA build level of a VSA.
+func verifiedLevel(levels []string) int {
+	level := -1
+	for _, l := range levels {
+		n, err := strconv.Atoi(strings.TrimPrefix(l, verifiedLevelPrefix))
+		if err != nil || !strings.HasPrefix(l, verifiedLevelPrefix) {
+			continue
+		}
+		if n > level {
+			level = n
+		}
+	}
+	return level
+}
@@ -0,0 +1,492 @@
+package admission
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/attestation"
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/vsa"
+)
+
+const (
+	echoServer     = "docker.io/org/echo-server@sha256:2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a"
+	databaseServer = "docker.io/org/database-server@sha256:4e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f"
+	testPrincipal  = "k8_sa://deployer@prod-project-id.iam.gserviceaccount.com"
+	testVerifierID = "https://github.com/org/verifier"
+)
+
+// testStore is a store indexed by image and predicate type.
+type testStore map[string][][]byte
+
+func (s testStore) Get(imageURI, predicateType string) ([][]byte, error) {
+	return s[imageURI+" "+predicateType], nil
+}
+
+func (s testStore) add(imageURI, predicateType string, content []byte) {
+	s[imageURI+" "+predicateType] = append(s[imageURI+" "+predicateType], content)
+}
+
+func trustRoot(t *testing.T, id string, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	root, err := json.Marshal(dsse.TrustRoot{
+		ID:        id,
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return root
+}
+
+// testPolicy creates a policy whose deployment attestations are signed
+// with the deployer key and whose VSAs are signed with the verifier key.
+func testPolicy(t *testing.T, deployerKey, verifierKey crypto.PublicKey) *Policy {
+	t.Helper()
+	policy, err := PolicyFromBytes([]byte(fmt.Sprintf(`{
+		"format": 1,
+		"trust": {"roots": [%s, %s]},
+		"rules": [
+			{
+				"namespaces": ["prod"],
+				"service_accounts": ["deployer"],
+				"principal": %q,
+				"principal_signers": ["deployer"]
+			},
+			{
+				"namespaces": ["staging"],
+				"vsa": {"verifier_id": %q, "require_slsa_level": 3, "signers": ["verifier"]},
+				"mode": "audit"
+			},
+			{
+				"namespaces": ["qa"],
+				"principal": "k8_sa://runner@qa-project-id.iam.gserviceaccount.com",
+				"principal_signers": ["deployer"]
+			}
+		]
+	}`, trustRoot(t, "deployer", deployerKey), trustRoot(t, "verifier", verifierKey), testPrincipal, testVerifierID)))
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	return policy
+}
+
+func sign(t *testing.T, signer dsse.Signer, statement any) []byte {
+	t.Helper()
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	envelope, err := dsse.Sign(signer, dsse.PayloadType, payload)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	content, err := json.Marshal(dsse.NewBundle(*envelope, signer.KeyID()))
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return content
+}
+
+func deploymentAttestation(t *testing.T, signer dsse.Signer, image, principal string) []byte {
+	t.Helper()
+	subject, err := intoto.SubjectFromImage(image)
+	if err != nil {
+		t.Fatalf("invalid image: %v", err)
+	}
+	return sign(t, signer, attestation.Statement{
+		Type:          intoto.StatementType,
+		Subject:       []intoto.ResourceDescriptor{subject},
+		PredicateType: attestation.PredicateType,
+		Predicate: attestation.Predicate{
+			Principal: attestation.Principal{URI: principal},
+			Package:   attestation.Package{Name: subject.Name},
+		},
+	})
+}
+
+func vsaAttestation(t *testing.T, signer dsse.Signer, image string, level int, metadata *vsa.Metadata) []byte {
+	t.Helper()
+	subject, err := intoto.SubjectFromImage(image)
+	if err != nil {
+		t.Fatalf("invalid image: %v", err)
+	}
+	return sign(t, signer, vsa.Statement{
+		Type:          intoto.StatementType,
+		Subject:       []intoto.ResourceDescriptor{subject},
+		PredicateType: vsa.PredicateType,
+		Predicate: vsa.Predicate{
+			Verifier:           vsa.Verifier{ID: testVerifierID},
+			ResourceURI:        subject.Name,
+			VerificationResult: vsa.ResultPassed,
+			VerifiedLevels:     []string{fmt.Sprintf("SLSA_BUILD_LEVEL_%d", level)},
+			Metadata:           metadata,
+		},
+	})
+}
+
+func newSigner(t *testing.T) dsse.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := dsse.NewSigner(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func readReview(t *testing.T, file string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return content
+}
+
+func Test_Webhook(t *testing.T) {
+	t.Parallel()
+
+	signer := newSigner(t)
+	verifier := newSigner(t)
+	untrusted := newSigner(t)
+	policy := testPolicy(t, signer.PublicKey(), verifier.PublicKey())
+
+	store := testStore{}
+	store.add(echoServer, attestation.PredicateType, deploymentAttestation(t, signer, echoServer, testPrincipal))
+	store.add(databaseServer, attestation.PredicateType, deploymentAttestation(t, signer, databaseServer, "k8_sa://other@prod-project-id.iam.gserviceaccount.com"))
+	staging := &vsa.Metadata{Namespace: "staging", Labels: map[string]string{"app": "database-server"}}
+	store.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier, databaseServer, 3, staging))
+
+	untrustedStore := testStore{}
+	untrustedStore.add(echoServer, attestation.PredicateType, deploymentAttestation(t, untrusted, echoServer, testPrincipal))
+
+	// The attestations are signed by trusted roots that may not sign them.
+	wrongSignerStore := testStore{}
+	wrongSignerStore.add(echoServer, attestation.PredicateType, deploymentAttestation(t, verifier, echoServer, testPrincipal))
+	wrongSignerStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, signer, databaseServer, 3, staging))
+
+	lowLevelStore := testStore{}
+	lowLevelStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier, databaseServer, 2, staging))
+
+	// The VSAs are for another namespace, for labels the workload does
+	// not have, or for no namespace.
+	otherResourceStore := testStore{}
+	otherResourceStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier, databaseServer, 3,
+		&vsa.Metadata{Namespace: "prod", Labels: map[string]string{"app": "database-server"}}))
+	otherResourceStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier, databaseServer, 3,
+		&vsa.Metadata{Namespace: "staging", Labels: map[string]string{"app": "database-server", "tier": "backend"}}))
+	otherResourceStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier, databaseServer, 3, nil))
+
+	// The attestations name other spellings of the images.
+	spellingStore := testStore{}
+	spellingStore.add(echoServer, attestation.PredicateType, deploymentAttestation(t, signer,
+		"index.docker.io/org/echo-server@sha256:2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a", testPrincipal))
+	spellingStore.add(databaseServer, vsa.PredicateType, vsaAttestation(t, verifier,
+		"docker://org/database-server:v1@sha256:4e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f", 3, staging))
+
+	tests := []struct {
+		name     string
+		file     string
+		store    Store
+		expected *Response
+	}{
+		{
+			name:     "pod",
+			file:     "pod.json",
+			store:    store,
+			expected: &Response{UID: "705ab4f5-6393-11e8-b7cc-42010a800002", Allowed: true},
+		},
+		{
+			name:  "pod without attestation",
+			file:  "pod.json",
+			store: testStore{},
+			expected: &Response{
+				UID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+				Result: &Status{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("image %q: no deployment attestation for principal %q", echoServer, testPrincipal),
+				},
+			},
+		},
+		{
+			name:  "pod with untrusted attestation",
+			file:  "pod.json",
+			store: untrustedStore,
+			expected: &Response{
+				UID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+				Result: &Status{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("image %q: no deployment attestation for principal %q", echoServer, testPrincipal),
+				},
+			},
+		},
+		{
+			name:  "pod with attestation from the wrong trust root",
+			file:  "pod.json",
+			store: wrongSignerStore,
+			expected: &Response{
+				UID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+				Result: &Status{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("image %q: no deployment attestation for principal %q", echoServer, testPrincipal),
+				},
+			},
+		},
+		{
+			name:     "pod with attestation for another spelling of the image",
+			file:     "pod.json",
+			store:    spellingStore,
+			expected: &Response{UID: "705ab4f5-6393-11e8-b7cc-42010a800002", Allowed: true},
+		},
+		{
+			name:  "pod with tag",
+			file:  "pod-tag.json",
+			store: store,
+			expected: &Response{
+				UID: "ab0f7c2e-8f4d-4d0b-9b8f-0e6f3c2a1d10",
+				Result: &Status{
+					Code:    http.StatusForbidden,
+					Message: `image "docker.io/org/echo-server:v1.2.3": no digest`,
+				},
+			},
+		},
+		{
+			name:  "job with an image for another principal",
+			file:  "job.json",
+			store: store,
+			expected: &Response{
+				UID: "6c1f0e2d-3b4a-4f5e-9d8c-7b6a5f4e3d2c",
+				Result: &Status{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("image %q: no deployment attestation for principal %q", databaseServer, testPrincipal),
+				},
+			},
+		},
+		{
+			name:     "cronjob",
+			file:     "cronjob.json",
+			store:    store,
+			expected: &Response{UID: "9a8b7c6d-5e4f-4a3b-2c1d-0e9f8a7b6c5d", Allowed: true},
+		},
+		{
+			name:     "deployment with VSA",
+			file:     "deployment.json",
+			store:    store,
+			expected: &Response{UID: "1b7e4a3c-2f1d-4c5e-8a9b-0c1d2e3f4a5b", Allowed: true},
+		},
+		{
+			name:     "deployment with VSA for another spelling of the image",
+			file:     "deployment.json",
+			store:    spellingStore,
+			expected: &Response{UID: "1b7e4a3c-2f1d-4c5e-8a9b-0c1d2e3f4a5b", Allowed: true},
+		},
+		{
+			name:  "deployment with audited VSA level",
+			file:  "deployment.json",
+			store: lowLevelStore,
+			expected: &Response{
+				UID:     "1b7e4a3c-2f1d-4c5e-8a9b-0c1d2e3f4a5b",
+				Allowed: true,
+				Warnings: []string{
+					fmt.Sprintf("image %q: no VSA from verifier %q at level 3 for namespace %q", databaseServer, testVerifierID, "staging"),
+				},
+			},
+		},
+		{
+			name:  "deployment with VSA from the wrong trust root",
+			file:  "deployment.json",
+			store: wrongSignerStore,
+			expected: &Response{
+				UID:     "1b7e4a3c-2f1d-4c5e-8a9b-0c1d2e3f4a5b",
+				Allowed: true,
+				Warnings: []string{
+					fmt.Sprintf("image %q: no VSA from verifier %q at level 3 for namespace %q", databaseServer, testVerifierID, "staging"),
+				},
+			},
+		},
+		{
+			name:  "deployment with VSA for another resource",
+			file:  "deployment.json",
+			store: otherResourceStore,
+			expected: &Response{
+				UID:     "1b7e4a3c-2f1d-4c5e-8a9b-0c1d2e3f4a5b",
+				Allowed: true,
+				Warnings: []string{
+					fmt.Sprintf("image %q: no VSA from verifier %q at level 3 for namespace %q", databaseServer, testVerifierID, "staging"),
+				},
+			},
+		},
+		{
+			name:     "unsupported kind",
+			file:     "configmap.json",
+			store:    testStore{},
+			expected: &Response{UID: "0d1e2f3a-4b5c-4d6e-8f7a-9b0c1d2e3f4a", Allowed: true},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(NewWebhook(policy, tt.store))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL, "application/json", bytes.NewReader(readReview(t, tt.file)))
+			if err != nil {
+				t.Fatalf("failed to post: %v", err)
+			}
+			defer resp.Body.Close()
+			if diff := cmp.Diff(http.StatusOK, resp.StatusCode); diff != "" {
+				t.Fatalf("unexpected status (-want +got): \n%s", diff)
+			}
+			var review Review
+			if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, review.Response); diff != "" {
+				t.Fatalf("unexpected response (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Webhook_namespaces(t *testing.T) {
+	t.Parallel()
+
+	signer := newSigner(t)
+	webhook := NewWebhook(testPolicy(t, signer.PublicKey(), signer.PublicKey()), testStore{})
+
+	var review Review
+	if err := json.Unmarshal(readReview(t, "pod.json"), &review); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	review.Request.Namespace = "dev"
+	expected := &Response{
+		UID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+		Result: &Status{
+			Code:    http.StatusForbidden,
+			Message: `no rule for namespace "dev" and service account "deployer"`,
+		},
+	}
+	if diff := cmp.Diff(expected, webhook.Review(review.Request)); diff != "" {
+		t.Fatalf("unexpected response (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Webhook_principal(t *testing.T) {
+	t.Parallel()
+
+	signer := newSigner(t)
+	store := testStore{}
+	store.add(echoServer, attestation.PredicateType, deploymentAttestation(t, signer, echoServer,
+		"k8_sa://runner@qa-project-id.iam.gserviceaccount.com"))
+	webhook := NewWebhook(testPolicy(t, signer.PublicKey(), signer.PublicKey()), store)
+
+	// The pods run as the deployer service account, not as the principal.
+	var review Review
+	if err := json.Unmarshal(readReview(t, "pod.json"), &review); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	review.Request.Namespace = "qa"
+	expected := &Response{
+		UID: "705ab4f5-6393-11e8-b7cc-42010a800002",
+		Result: &Status{
+			Code: http.StatusForbidden,
+			Message: fmt.Sprintf(`image %q: principal "k8_sa://runner@qa-project-id.iam.gserviceaccount.com" is not service account "deployer"`,
+				echoServer),
+		},
+	}
+	if diff := cmp.Diff(expected, webhook.Review(review.Request)); diff != "" {
+		t.Fatalf("unexpected response (-want +got): \n%s", diff)
+	}
+}
+
+func Test_PolicyFromBytes(t *testing.T) {
+	t.Parallel()
+
+	root := `{"id": "key", "public_key": "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=\n-----END PUBLIC KEY-----\n"}`
+	tests := []struct {
+		name     string
+		policy   string
+		expected bool
+	}{
+		{
+			name:     "valid policy",
+			policy:   `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"principal": "k8_sa://name", "principal_signers": ["key"]}]}`,
+			expected: true,
+		},
+		{
+			name:   "no trust roots",
+			policy: `{"format": 1, "rules": [{"principal": "k8_sa://name", "principal_signers": ["key"]}]}`,
+		},
+		{
+			name:   "invalid principal",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"principal": "spiffe://name", "principal_signers": ["key"]}]}`,
+		},
+		{
+			name:   "no principal signers",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"principal": "k8_sa://name"}]}`,
+		},
+		{
+			name:   "unknown principal signer",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"principal": "k8_sa://name", "principal_signers": ["other"]}]}`,
+		},
+		{
+			name:   "no vsa signers",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"vsa": {"verifier_id": "id"}}]}`,
+		},
+		{
+			name:   "unknown vsa signer",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"vsa": {"verifier_id": "id", "signers": ["other"]}}]}`,
+		},
+		{
+			name:   "no rules",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}}`,
+		},
+		{
+			name:   "no principal or vsa",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"namespaces": ["prod"]}]}`,
+		},
+		{
+			name:   "invalid mode",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"principal": "k8_sa://name", "principal_signers": ["key"], "mode": "warn"}]}`,
+		},
+		{
+			name:   "invalid level",
+			policy: `{"format": 1, "trust": {"roots": [` + root + `]}, "rules": [{"vsa": {"verifier_id": "id", "require_slsa_level": 5, "signers": ["key"]}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := PolicyFromBytes([]byte(tt.policy))
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
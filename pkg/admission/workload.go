@@ -0,0 +1,119 @@
+package admission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const defaultServiceAccount = "default"
+
+type objectMeta struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type container struct {
+	Image string `json:"image"`
+}
+
+type podSpec struct {
+	ServiceAccountName  string      `json:"serviceAccountName"`
+	InitContainers      []container `json:"initContainers"`
+	Containers          []container `json:"containers"`
+	EphemeralContainers []container `json:"ephemeralContainers"`
+}
+
+type podTemplate struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+type pod struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     podSpec    `json:"spec"`
+}
+
+// deployment is a Deployment or a Job: both have a pod template.
+type deployment struct {
+	Spec struct {
+		Template podTemplate `json:"template"`
+	} `json:"spec"`
+}
+
+type cronJob struct {
+	Spec struct {
+		JobTemplate deployment `json:"jobTemplate"`
+	} `json:"spec"`
+}
+
+// workload contains the fields of the pods an object creates.
+type workload struct {
+	namespace      string
+	labels         map[string]string
+	serviceAccount string
+	images         []string
+}
+
+// supported returns true if the webhook checks objects of the kind.
+func supported(kind GroupVersionKind) bool {
+	switch {
+	case kind.Group == "" && kind.Kind == "Pod",
+		kind.Group == "apps" && kind.Kind == "Deployment",
+		kind.Group == "batch" && kind.Kind == "Job",
+		kind.Group == "batch" && kind.Kind == "CronJob":
+		return true
+	default:
+		return false
+	}
+}
+
+// workloadFromRequest returns the workload of the object of a request.
+// For controllers, the labels are those of their pod template.
+func workloadFromRequest(req *Request) (*workload, error) {
+	var template podTemplate
+	switch req.Kind.Kind {
+	case "Pod":
+		var p pod
+		if err := json.Unmarshal(req.Object, &p); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		template = podTemplate(p)
+	case "Deployment", "Job":
+		var d deployment
+		if err := json.Unmarshal(req.Object, &d); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		template = d.Spec.Template
+	case "CronJob":
+		var c cronJob
+		if err := json.Unmarshal(req.Object, &c); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		template = c.Spec.JobTemplate.Spec.Template
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", req.Kind.Kind)
+	}
+
+	w := workload{
+		namespace:      req.Namespace,
+		labels:         template.Metadata.Labels,
+		serviceAccount: template.Spec.ServiceAccountName,
+	}
+	if w.serviceAccount == "" {
+		w.serviceAccount = defaultServiceAccount
+	}
+	seen := make(map[string]bool)
+	for _, containers := range [][]container{template.Spec.InitContainers, template.Spec.Containers, template.Spec.EphemeralContainers} {
+		for i := range containers {
+			image := containers[i].Image
+			if !seen[image] {
+				seen[image] = true
+				w.images = append(w.images, image)
+			}
+		}
+	}
+	if len(w.images) == 0 {
+		return nil, errors.New("no containers")
+	}
+	return &w, nil
+}
@@ -0,0 +1,36 @@
+// Package attestation defines the deployment attestation, created
+// when a package passes the deployment policy of a principal.
+package attestation
+
+import "github.com/laurentsimon/slsa-e2e/pkg/intoto"
+
+// PredicateType is the type of the deployment predicate.
+const PredicateType = "https://slsa.dev/deployment/v0.1"
+
+type Principal struct {
+	URI string `json:"uri"`
+}
+
+type Package struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+}
+
+type Deployer struct {
+	ID string `json:"id"`
+}
+
+// Predicate is the deployment predicate.
+type Predicate struct {
+	CreationTime string    `json:"creationTime"`
+	Principal    Principal `json:"principal"`
+	Package      Package   `json:"package"`
+	Deployer     Deployer  `json:"deployer"`
+	// Release is the release attestation the deployment was derived from.
+	Release intoto.ResourceDescriptor `json:"release"`
+	// Policy contains the policy files the package was verified against.
+	// The URI of the deployment policy is its policy ID.
+	Policy []intoto.ResourceDescriptor `json:"policy"`
+}
+
+type Statement = intoto.Statement[Predicate]
@@ -0,0 +1,40 @@
+package deployment
+
+import (
+	"time"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/attestation"
+	internal "github.com/laurentsimon/slsa-e2e/pkg/deployment/internal"
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+// Policy defines a deployment policy.
+type Policy struct {
+	policy *internal.Policy
+}
+
+// FromFiles builds a policy from an org file and a directory
+// containing the deployment policy files.
+func FromFiles(org, dir string) (*Policy, error) {
+	policy, err := internal.FromFiles(org, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{
+		policy: policy,
+	}, nil
+}
+
+// Evaluate evaluates the policy identified by policyID for an image of the form name@digest.
+func (p *Policy) Evaluate(imageURI, policyID, deployerID string, verifier options.ReleaseVerifier) results.Verification {
+	return p.policy.Evaluate(imageURI, policyID, deployerID, verifier)
+}
+
+// Attest evaluates the policy identified by policyID for an image of the form name@digest.
+// If the deployment passes, it returns the deployment attestation of the image.
+func (p *Policy) Attest(imageURI, policyID, deployerID string, verifier options.ReleaseVerifier,
+	creationTime time.Time,
+) (*attestation.Statement, results.Verification) {
+	return p.policy.Attest(imageURI, policyID, deployerID, verifier, creationTime)
+}
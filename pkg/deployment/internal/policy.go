@@ -0,0 +1,430 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/attestation"
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/imageref"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+type ReleaseBuild struct {
+	MaxSlsaLevel int `json:"max_slsa_level"`
+}
+
+type Root struct {
+	ID    string       `json:"id"`
+	Build ReleaseBuild `json:"build"`
+}
+
+// Deployer is a trusted deployer, identified by the ID passed
+// to the evaluation.
+type Deployer struct {
+	ID string `json:"id"`
+}
+
+type Roots struct {
+	Release []Root `json:"release"`
+	// Deploy is optional: without it, any deployer may evaluate the policy.
+	Deploy []Deployer `json:"deploy"`
+}
+
+type OrgPolicy struct {
+	Format int   `json:"format"`
+	Roots  Roots `json:"roots"`
+}
+
+type Principal struct {
+	URI string `json:"uri"`
+}
+
+type Build struct {
+	RequireSlsaLevel int `json:"require_slsa_level"`
+}
+
+type Environment struct {
+	AnyOf []string `json:"any_of"`
+}
+
+type Package struct {
+	Name        string       `json:"name"`
+	Environment *Environment `json:"environment"`
+}
+
+type ProjectPolicy struct {
+	Format    int       `json:"format"`
+	Principal Principal `json:"principal"`
+	Build     Build     `json:"build"`
+	Packages  []Package `json:"packages"`
+}
+
+type context string
+
+const (
+	contextOrg     context = "org"
+	contextProject context = "project"
+)
+
+const maxSlsaLevel = 4
+
+// digestAlgorithm is the digest algorithm required to pin images.
+const digestAlgorithm = "sha256"
+
+type Policy struct {
+	orgPolicy OrgPolicy
+	// Project policies indexed by policy ID.
+	projectPolicies map[string]ProjectPolicy
+	// Descriptors of the policy files, recorded in deployment attestations.
+	orgFile      intoto.ResourceDescriptor
+	projectFiles map[string]intoto.ResourceDescriptor
+}
+
+// FromBytes creates a policy from the org policy and project policies
+// indexed by their policy ID.
+func FromBytes(org []byte, projects map[string][]byte) (*Policy, error) {
+	return fromBytes("", org, projects)
+}
+
+// fromBytes creates a policy from the org policy read from orgPath,
+// which is empty if the policy was not read from a file.
+func fromBytes(orgPath string, org []byte, projects map[string][]byte) (*Policy, error) {
+	var orgPolicy OrgPolicy
+	if err := json.Unmarshal(org, &orgPolicy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if err := validateOrgPolicy(orgPolicy); err != nil {
+		return nil, err
+	}
+
+	projectPolicies := make(map[string]ProjectPolicy, len(projects))
+	projectFiles := make(map[string]intoto.ResourceDescriptor, len(projects))
+	for id, content := range projects {
+		var projectPolicy ProjectPolicy
+		if err := json.Unmarshal(content, &projectPolicy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %q: %w", id, err)
+		}
+		if err := validateProjectPolicy(id, projectPolicy); err != nil {
+			return nil, err
+		}
+		// Packages are looked up and attested by their canonical name.
+		// NOTE: the names were validated above.
+		for i := range projectPolicy.Packages {
+			projectPolicy.Packages[i].Name, _ = packageName(projectPolicy.Packages[i].Name)
+		}
+		projectPolicies[id] = projectPolicy
+		projectFiles[id] = intoto.Descriptor(id, content)
+	}
+
+	return &Policy{
+		orgPolicy:       orgPolicy,
+		projectPolicies: projectPolicies,
+		orgFile:         intoto.Descriptor(orgPath, org),
+		projectFiles:    projectFiles,
+	}, nil
+}
+
+// FromFiles reads the org policy and every project policy under dir.
+// The policy ID of a project is its path relative to dir.
+// The org file is skipped if it lives under dir.
+func FromFiles(org, dir string) (*Policy, error) {
+	orgContent, err := os.ReadFile(org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	orgPath, err := filepath.Abs(org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	contents := make(map[string][]byte)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		if abs == orgPath {
+			return nil
+		}
+		id, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		contents[filepath.ToSlash(id)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromBytes(org, orgContent, contents)
+}
+
+func validateOrgPolicy(p OrgPolicy) error {
+	if p.Format != 1 {
+		return fmt.Errorf("%q policy: invalid %q", contextOrg, "format")
+	}
+	if len(p.Roots.Release) == 0 {
+		return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.release")
+	}
+	for i := range p.Roots.Release {
+		root := &p.Roots.Release[i]
+		if root.ID == "" {
+			return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.release.id")
+		}
+		if root.Build.MaxSlsaLevel < 0 || root.Build.MaxSlsaLevel > maxSlsaLevel {
+			return fmt.Errorf("%q policy: invalid %q: %d", contextOrg, "roots.release.build.max_slsa_level", root.Build.MaxSlsaLevel)
+		}
+	}
+	if p.Roots.Deploy != nil && len(p.Roots.Deploy) == 0 {
+		return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.deploy")
+	}
+	for i := range p.Roots.Deploy {
+		if p.Roots.Deploy[i].ID == "" {
+			return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.deploy.id")
+		}
+	}
+	return nil
+}
+
+func validateProjectPolicy(id string, p ProjectPolicy) error {
+	if p.Format != 1 {
+		return fmt.Errorf("%q policy %q: invalid %q", contextProject, id, "format")
+	}
+	if p.Principal.URI == "" {
+		return fmt.Errorf("%q policy %q: empty %q", contextProject, id, "principal.uri")
+	}
+	if p.Build.RequireSlsaLevel < 0 || p.Build.RequireSlsaLevel > maxSlsaLevel {
+		return fmt.Errorf("%q policy %q: invalid %q: %d", contextProject, id, "build.require_slsa_level", p.Build.RequireSlsaLevel)
+	}
+	if len(p.Packages) == 0 {
+		return fmt.Errorf("%q policy %q: empty %q", contextProject, id, "packages")
+	}
+	names := make(map[string]bool, len(p.Packages))
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		if pkg.Name == "" {
+			return fmt.Errorf("%q policy %q: empty %q", contextProject, id, "packages.name")
+		}
+		name, err := packageName(pkg.Name)
+		if err != nil {
+			return fmt.Errorf("%q policy %q: invalid %q: %w", contextProject, id, "packages.name", err)
+		}
+		if names[name] {
+			return fmt.Errorf("%q policy %q: duplicate package %q", contextProject, id, pkg.Name)
+		}
+		names[name] = true
+		if pkg.Environment != nil && len(pkg.Environment.AnyOf) == 0 {
+			return fmt.Errorf("%q policy %q: empty %q", contextProject, id, "packages.environment.any_of")
+		}
+	}
+	return nil
+}
+
+func (p *Policy) Evaluate(imageURI, policyID, deployerID string, verifier options.ReleaseVerifier) results.Verification {
+	_, result := p.evaluate(imageURI, policyID, deployerID, verifier)
+	return result
+}
+
+// Attest evaluates the policy and returns the deployment attestation
+// of the image if the deployment passes.
+func (p *Policy) Attest(imageURI, policyID, deployerID string, verifier options.ReleaseVerifier,
+	creationTime time.Time,
+) (*attestation.Statement, results.Verification) {
+	statement, result := p.evaluate(imageURI, policyID, deployerID, verifier)
+	if !result.Pass() {
+		return nil, result
+	}
+	statement.Predicate.CreationTime = creationTime.UTC().Format(time.RFC3339)
+	return statement, result
+}
+
+// evaluate evaluates the policy. If the deployment passes, it returns
+// the deployment attestation without its creation time.
+func (p *Policy) evaluate(imageURI, policyID, deployerID string, verifier options.ReleaseVerifier) (*attestation.Statement, results.Verification) {
+	if verifier == nil {
+		return nil, results.VerificationInvalid(errors.New("no release verifier"))
+	}
+	if deployerID == "" {
+		return nil, results.VerificationInvalid(errors.New("empty deployer ID"))
+	}
+	imageName, digest, err := parseImage(imageURI)
+	if err != nil {
+		return nil, results.VerificationInvalid(err)
+	}
+	alg, value, _ := strings.Cut(digest, ":")
+	if alg != digestAlgorithm {
+		return nil, results.VerificationInvalid(fmt.Errorf("image %q: digest must be %s", imageURI, digestAlgorithm))
+	}
+
+	project, ok := p.projectPolicies[policyID]
+	if !ok {
+		return nil, results.VerificationInvalid(fmt.Errorf("%q: no policy with ID %q", contextProject, policyID))
+	}
+
+	// 1. Verify the deployer is trusted by the org.
+	if !p.trustedDeployer(deployerID) {
+		return nil, results.VerificationFail(fmt.Errorf("%q: deployer %q not allowed", contextOrg, deployerID))
+	}
+
+	// 2. Verify the package is allowed for this principal.
+	pkg := findPackage(project, imageName)
+	if pkg == nil {
+		return nil, results.VerificationFail(fmt.Errorf("%q: package %q not allowed for principal %q",
+			contextProject, imageName, project.Principal.URI))
+	}
+
+	// 3. Verify the release attestations of each trusted releaser.
+	var errs []error
+	for i := range p.orgPolicy.Roots.Release {
+		root := &p.orgPolicy.Roots.Release[i]
+		attestations, err := verifier.VerifyReleaseAttestation(digest, imageName, root.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("releaser %q: %w", root.ID, err))
+			continue
+		}
+		for j := range attestations {
+			// An attestation for another image is skipped: it does not
+			// prevent another attestation from passing.
+			if !intoto.HasDigest(attestations[j].Subjects, alg, value) {
+				errs = append(errs, fmt.Errorf("releaser %q: digest %q not in the attestation subjects", root.ID, digest))
+				continue
+			}
+			if err := verifyReleaseAttestation(*root, project, *pkg, attestations[j]); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			return p.statement(imageName, alg, value, policyID, deployerID, project, attestations[j]), results.VerificationPass()
+		}
+	}
+	if len(errs) == 0 {
+		return nil, results.VerificationFail(fmt.Errorf("%q: no release attestation", contextOrg))
+	}
+	return nil, results.VerificationFail(fmt.Errorf("%q: %w", contextOrg, errors.Join(errs...)))
+}
+
+// trustedDeployer returns true if the org trusts the deployer.
+// Every deployer is trusted if the org declares no deployers.
+func (p *Policy) trustedDeployer(deployerID string) bool {
+	if p.orgPolicy.Roots.Deploy == nil {
+		return true
+	}
+	for i := range p.orgPolicy.Roots.Deploy {
+		if p.orgPolicy.Roots.Deploy[i].ID == deployerID {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) statement(imageName, alg, value, policyID, deployerID string, project ProjectPolicy,
+	release options.ReleaseAttestation,
+) *attestation.Statement {
+	return &attestation.Statement{
+		Type: intoto.StatementType,
+		Subject: []intoto.ResourceDescriptor{
+			{Name: imageName, Digest: map[string]string{alg: value}},
+		},
+		PredicateType: attestation.PredicateType,
+		Predicate: attestation.Predicate{
+			Principal: attestation.Principal{
+				URI: project.Principal.URI,
+			},
+			Package: attestation.Package{
+				Name:        imageName,
+				Environment: release.Environment,
+			},
+			Deployer: attestation.Deployer{
+				ID: deployerID,
+			},
+			Release: release.Descriptor,
+			Policy:  []intoto.ResourceDescriptor{p.orgFile, p.projectFiles[policyID]},
+		},
+	}
+}
+
+func verifyReleaseAttestation(root Root, project ProjectPolicy, pkg Package, att options.ReleaseAttestation) error {
+	// Ceiling: the releaser cannot vouch for a level above its own.
+	if att.BuildLevel > root.Build.MaxSlsaLevel {
+		return fmt.Errorf("releaser %q: attested level %d above its maximum level %d",
+			root.ID, att.BuildLevel, root.Build.MaxSlsaLevel)
+	}
+	// Floor: the principal requires a minimum level.
+	if att.BuildLevel < project.Build.RequireSlsaLevel {
+		return fmt.Errorf("releaser %q: attested level %d, principal requires %d",
+			root.ID, att.BuildLevel, project.Build.RequireSlsaLevel)
+	}
+	if err := verifyEnvironment(pkg.Environment, att.Environment); err != nil {
+		return fmt.Errorf("releaser %q: %w", root.ID, err)
+	}
+	return nil
+}
+
+func findPackage(project ProjectPolicy, name string) *Package {
+	for i := range project.Packages {
+		pkg := &project.Packages[i]
+		if pkg.Name == name {
+			return pkg
+		}
+	}
+	return nil
+}
+
+func verifyEnvironment(policyEnv *Environment, environment string) error {
+	if policyEnv == nil {
+		if environment != "" {
+			return fmt.Errorf("environment %q not allowed", environment)
+		}
+		return nil
+	}
+	for i := range policyEnv.AnyOf {
+		if policyEnv.AnyOf[i] == environment {
+			return nil
+		}
+	}
+	return fmt.Errorf("environment %q not allowed", environment)
+}
+
+// parseImage returns the canonical name and the digest of an image.
+// The tag, if any, is dropped.
+func parseImage(imageURI string) (string, string, error) {
+	ref, err := imageref.Parse(imageURI)
+	if err != nil {
+		return "", "", err
+	}
+	if ref.Digest == "" {
+		return "", "", fmt.Errorf("image %q: no digest", imageURI)
+	}
+	return ref.Name(), ref.Digest, nil
+}
+
+// packageName returns the canonical name of a package, which is
+// an image without a tag or a digest.
+func packageName(name string) (string, error) {
+	ref, err := imageref.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	if ref.Tag != "" || ref.Digest != "" {
+		return "", fmt.Errorf("image %q: unexpected tag or digest", name)
+	}
+	return ref.Name(), nil
+}
@@ -0,0 +1,433 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/attestation"
+	"github.com/laurentsimon/slsa-e2e/pkg/deployment/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+const testOrg = `{
+    "format": 1,
+    "roots": {
+        "release": [
+            {
+                "id": "https://github.com/org/.github/workflows/image-releaser.yml@refs/heads/main",
+                "build": {
+                    "max_slsa_level": 3
+                }
+            }
+        ],
+        "deploy": [
+            {
+                "id": "https://github.com/org/.github/workflows/image-deployer.yml@refs/heads/main"
+            }
+        ]
+    }
+}`
+
+const testProject = `{
+    "format": 1,
+    "principal": {
+        "uri": "k8_sa://name@prod-project-id.iam.gserviceaccount.com"
+    },
+    "build": {
+        "require_slsa_level": 3
+    },
+    "packages": [
+        {
+            "name": "docker.io/org/echo-server",
+            "environment": {
+                "any_of": ["prod"]
+            }
+        },
+        {
+            "name": "docker.io/org/database-server"
+        }
+    ]
+}`
+
+const testReleaserID = "https://github.com/org/.github/workflows/image-releaser.yml@refs/heads/main"
+
+const testDeployerID = "https://github.com/org/.github/workflows/image-deployer.yml@refs/heads/main"
+
+type testVerifier struct {
+	attestations []options.ReleaseAttestation
+}
+
+func (v testVerifier) VerifyReleaseAttestation(digest, imageName, releaserID string) ([]options.ReleaseAttestation, error) {
+	if releaserID != testReleaserID {
+		return nil, errors.New("unknown releaser")
+	}
+	// Attestations are for the image unless the test sets their subjects.
+	alg, value, _ := strings.Cut(digest, ":")
+	attestations := make([]options.ReleaseAttestation, len(v.attestations))
+	for i := range v.attestations {
+		attestations[i] = v.attestations[i]
+		if attestations[i].Subjects == nil {
+			attestations[i].Subjects = []intoto.ResourceDescriptor{
+				{Name: imageName, Digest: map[string]string{alg: value}},
+			}
+		}
+	}
+	return attestations, nil
+}
+
+func Test_FromBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		org      string
+		projects map[string]string
+		expected bool
+	}{
+		{
+			name:     "valid policies",
+			org:      testOrg,
+			projects: map[string]string{"servers-prod.json": testProject},
+			expected: true,
+		},
+		{
+			name:     "empty org roots",
+			org:      `{"format": 1}`,
+			expected: false,
+		},
+		{
+			name:     "invalid max level",
+			org:      `{"format": 1, "roots": {"release": [{"id": "id", "build": {"max_slsa_level": 5}}], "deploy": [{"id": "id"}]}}`,
+			expected: false,
+		},
+		{
+			name:     "no deployers",
+			org:      `{"format": 1, "roots": {"release": [{"id": "id", "build": {"max_slsa_level": 3}}]}}`,
+			expected: true,
+		},
+		{
+			name:     "empty deployers",
+			org:      `{"format": 1, "roots": {"release": [{"id": "id", "build": {"max_slsa_level": 3}}], "deploy": []}}`,
+			expected: false,
+		},
+		{
+			name:     "empty deployer ID",
+			org:      `{"format": 1, "roots": {"release": [{"id": "id", "build": {"max_slsa_level": 3}}], "deploy": [{"id": ""}]}}`,
+			expected: false,
+		},
+		{
+			name: "empty principal",
+			org:  testOrg,
+			projects: map[string]string{
+				"servers-prod.json": `{"format": 1, "packages": [{"name": "docker.io/org/echo-server"}]}`,
+			},
+			expected: false,
+		},
+		{
+			name: "duplicate packages",
+			org:  testOrg,
+			projects: map[string]string{
+				"servers-prod.json": `{
+					"format": 1,
+					"principal": {"uri": "k8_sa://name@project"},
+					"packages": [{"name": "docker.io/org/echo-server"}, {"name": "docker.io/org/echo-server"}]
+				}`,
+			},
+			expected: false,
+		},
+		{
+			name: "duplicate packages in another spelling",
+			org:  testOrg,
+			projects: map[string]string{
+				"servers-prod.json": `{
+					"format": 1,
+					"principal": {"uri": "k8_sa://name@project"},
+					"packages": [{"name": "docker.io/org/echo-server"}, {"name": "docker://org/echo-server"}]
+				}`,
+			},
+			expected: false,
+		},
+		{
+			name: "package with a digest",
+			org:  testOrg,
+			projects: map[string]string{
+				"servers-prod.json": `{
+					"format": 1,
+					"principal": {"uri": "k8_sa://name@project"},
+					"packages": [{"name": "docker.io/org/echo-server@sha256:abcd"}]
+				}`,
+			},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			projects := make(map[string][]byte, len(tt.projects))
+			for id, content := range tt.projects {
+				projects[id] = []byte(content)
+			}
+			_, err := FromBytes([]byte(tt.org), projects)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		imageURI     string
+		policyID     string
+		deployerID   string
+		attestations []options.ReleaseAttestation
+		expected     func(results.Verification) bool
+	}{
+		{
+			name:     "pass",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "staging", BuildLevel: 3},
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "pass other spelling of the image",
+			imageURI: "index.docker.io/org/echo-server:v1@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "pass no environment",
+			imageURI: "docker.io/org/database-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{BuildLevel: 3},
+			},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "environment not allowed",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "staging", BuildLevel: 3},
+			},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "level below floor",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 2},
+			},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "level above ceiling",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 4},
+			},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "no attestation",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "package not allowed",
+			imageURI: "docker.io/org/unknown@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "unknown policy",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-dev.json",
+			expected: results.Verification.Invalid,
+		},
+		{
+			name:     "subject digest mismatch",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{
+					Environment: "prod",
+					BuildLevel:  3,
+					Subjects: []intoto.ResourceDescriptor{
+						{Name: "docker.io/org/echo-server", Digest: map[string]string{"sha256": "efgh"}},
+					},
+				},
+			},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "subject digest mismatch then match",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{
+					Environment: "prod",
+					BuildLevel:  3,
+					Subjects: []intoto.ResourceDescriptor{
+						{Name: "docker.io/org/echo-server", Digest: map[string]string{"sha256": "efgh"}},
+					},
+				},
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "digest not sha256",
+			imageURI: "docker.io/org/echo-server@sha512:abcd",
+			policyID: "servers-prod.json",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Invalid,
+		},
+		{
+			name:     "no digest",
+			imageURI: "docker.io/org/echo-server",
+			policyID: "servers-prod.json",
+			expected: results.Verification.Invalid,
+		},
+		{
+			name:       "deployer not allowed",
+			imageURI:   "docker.io/org/echo-server@sha256:abcd",
+			policyID:   "servers-prod.json",
+			deployerID: "https://github.com/other/.github/workflows/image-deployer.yml@refs/heads/main",
+			attestations: []options.ReleaseAttestation{
+				{Environment: "prod", BuildLevel: 3},
+			},
+			expected: results.Verification.Fail,
+		},
+	}
+	policy, err := FromBytes([]byte(testOrg), map[string][]byte{"servers-prod.json": []byte(testProject)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			deployerID := tt.deployerID
+			if deployerID == "" {
+				deployerID = testDeployerID
+			}
+			result := policy.Evaluate(tt.imageURI, tt.policyID, deployerID, testVerifier{attestations: tt.attestations})
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_noDeployers(t *testing.T) {
+	t.Parallel()
+
+	// Without deployers, the org trusts any deployer.
+	org := `{"format": 1, "roots": {"release": [{"id": "` + testReleaserID + `", "build": {"max_slsa_level": 3}}]}}`
+	policy, err := FromBytes([]byte(org), map[string][]byte{"servers-prod.json": []byte(testProject)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	result := policy.Evaluate("docker.io/org/echo-server@sha256:abcd", "servers-prod.json",
+		"https://github.com/other/.github/workflows/image-deployer.yml@refs/heads/main",
+		testVerifier{attestations: []options.ReleaseAttestation{{Environment: "prod", BuildLevel: 3}}})
+	if !result.Pass() {
+		t.Fatalf("unexpected result: %v", result)
+	}
+}
+
+func Test_Attest(t *testing.T) {
+	t.Parallel()
+
+	release := intoto.Descriptor("", []byte("release"))
+	verifier := testVerifier{
+		attestations: []options.ReleaseAttestation{
+			{Environment: "prod", BuildLevel: 3, Descriptor: release},
+		},
+	}
+	creationTime := time.Date(2023, 7, 1, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	policy, err := fromBytes("org.json", []byte(testOrg), map[string][]byte{"servers-prod.json": []byte(testProject)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		imageURI string
+		expected *attestation.Statement
+	}{
+		{
+			name:     "pass",
+			imageURI: "docker.io/org/echo-server@sha256:abcd",
+			expected: &attestation.Statement{
+				Type: intoto.StatementType,
+				Subject: []intoto.ResourceDescriptor{
+					{Name: "docker.io/org/echo-server", Digest: map[string]string{"sha256": "abcd"}},
+				},
+				PredicateType: attestation.PredicateType,
+				Predicate: attestation.Predicate{
+					CreationTime: "2023-07-01T18:00:00Z",
+					Principal: attestation.Principal{
+						URI: "k8_sa://name@prod-project-id.iam.gserviceaccount.com",
+					},
+					Package: attestation.Package{
+						Name:        "docker.io/org/echo-server",
+						Environment: "prod",
+					},
+					Deployer: attestation.Deployer{ID: testDeployerID},
+					Release:  release,
+					Policy: []intoto.ResourceDescriptor{
+						intoto.Descriptor("org.json", []byte(testOrg)),
+						intoto.Descriptor("servers-prod.json", []byte(testProject)),
+					},
+				},
+			},
+		},
+		{
+			name:     "fail",
+			imageURI: "docker.io/org/database-server@sha256:abcd",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			statement, result := policy.Attest(tt.imageURI, "servers-prod.json", testDeployerID, verifier, creationTime)
+			if diff := cmp.Diff(tt.expected != nil, result.Pass()); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", result, diff)
+			}
+			if diff := cmp.Diff(tt.expected, statement); diff != "" {
+				t.Fatalf("unexpected statement (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
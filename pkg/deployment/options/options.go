@@ -0,0 +1,22 @@
+package options
+
+import "github.com/laurentsimon/slsa-e2e/pkg/intoto"
+
+// ReleaseAttestation contains the verified fields of a release attestation.
+type ReleaseAttestation struct {
+	Environment string
+	BuildLevel  int
+	// Subjects are the subjects of the attestation. One of them
+	// must have the digest of the evaluated image.
+	Subjects []intoto.ResourceDescriptor
+	// Descriptor identifies the attestation by its digest.
+	// It is recorded in deployment attestations.
+	Descriptor intoto.ResourceDescriptor
+}
+
+// ReleaseVerifier verifies release attestations.
+type ReleaseVerifier interface {
+	// VerifyReleaseAttestation returns the release attestations for the image
+	// identified by imageName and digest that were created by releaserID.
+	VerifyReleaseAttestation(digest, imageName, releaserID string) ([]ReleaseAttestation, error)
+}
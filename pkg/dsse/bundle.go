@@ -0,0 +1,32 @@
+package dsse
+
+// BundleMediaType is the media type of the bundles this package creates.
+const BundleMediaType = "application/vnd.dev.sigstore.bundle+json;version=0.2"
+
+// Bundle is a Sigstore bundle that contains a DSSE envelope.
+// Only the fields needed for offline verification are defined.
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	DsseEnvelope         *Envelope            `json:"dsseEnvelope"`
+}
+
+type VerificationMaterial struct {
+	PublicKey            *PublicKeyIdentifier  `json:"publicKey,omitempty"`
+	X509CertificateChain *X509CertificateChain `json:"x509CertificateChain,omitempty"`
+	Certificate          *X509Certificate      `json:"certificate,omitempty"`
+	TlogEntries          []any                 `json:"tlogEntries"`
+}
+
+type PublicKeyIdentifier struct {
+	Hint string `json:"hint"`
+}
+
+type X509CertificateChain struct {
+	Certificates []X509Certificate `json:"certificates"`
+}
+
+// X509Certificate is a base64-encoded DER certificate.
+type X509Certificate struct {
+	RawBytes string `json:"rawBytes"`
+}
@@ -0,0 +1,69 @@
+// Package dsse verifies and signs DSSE envelopes
+// and Sigstore bundles that contain them.
+package dsse
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PayloadType is the payload type of in-toto statements.
+const PayloadType = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// PAE returns the pre-authentication encoding of a payload,
+// which is what signatures sign.
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// DecodePayload returns the decoded payload of the envelope.
+func (e *Envelope) DecodePayload() ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	return payload, nil
+}
+
+// Parse reads a DSSE envelope, or a Sigstore bundle that contains one.
+// The bundle is nil for plain envelopes.
+func Parse(content []byte) (*Envelope, *Bundle, error) {
+	var fields struct {
+		MediaType    string    `json:"mediaType"`
+		Payload      string    `json:"payload"`
+		DsseEnvelope *Envelope `json:"dsseEnvelope"`
+	}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	switch {
+	case fields.DsseEnvelope != nil:
+		var bundle Bundle
+		if err := json.Unmarshal(content, &bundle); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		return bundle.DsseEnvelope, &bundle, nil
+	case fields.Payload != "":
+		var envelope Envelope
+		if err := json.Unmarshal(content, &envelope); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		return &envelope, nil, nil
+	default:
+		return nil, nil, errors.New("not a DSSE envelope or bundle")
+	}
+}
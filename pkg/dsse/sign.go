@@ -0,0 +1,123 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Signer signs envelopes. It is implemented by local keys,
+// and can be implemented by key management services.
+type Signer interface {
+	// KeyID returns the identifier of the key.
+	KeyID() string
+	// PublicKey returns the public key that verifies the signatures.
+	PublicKey() crypto.PublicKey
+	// Sign signs the message. ECDSA signers hash the message first.
+	Sign(message []byte) ([]byte, error)
+}
+
+// keySigner signs with a local ECDSA or Ed25519 key.
+type keySigner struct {
+	key   crypto.Signer
+	keyID string
+}
+
+// NewSigner returns a signer for an ECDSA or Ed25519 private key.
+// The key ID is the SHA256 digest of the DER public key.
+func NewSigner(key crypto.Signer) (Signer, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	digest := sha256.Sum256(der)
+	return &keySigner{key: key, keyID: hex.EncodeToString(digest[:])}, nil
+}
+
+// LoadSigner returns a signer for a PEM private key file,
+// in PKCS #8 or SEC 1 form.
+func LoadSigner(path string) (Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return NewSigner(signer)
+}
+
+func (s *keySigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *keySigner) PublicKey() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *keySigner) Sign(message []byte) ([]byte, error) {
+	if k, ok := s.key.(*ecdsa.PrivateKey); ok {
+		return ecdsa.SignASN1(rand.Reader, k, digest(ecdsaHash(k.Curve), message))
+	}
+	return s.key.Sign(rand.Reader, message, crypto.Hash(0))
+}
+
+// Sign signs the payload and returns the envelope.
+func Sign(signer Signer, payloadType string, payload []byte) (*Envelope, error) {
+	sig, err := signer.Sign(PAE(payloadType, payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []Signature{
+			{KeyID: signer.KeyID(), Sig: base64.StdEncoding.EncodeToString(sig)},
+		},
+	}, nil
+}
+
+// NewBundle returns a bundle for an envelope signed by a key.
+// The bundle has no transparency log entry: it is verified with
+// the public key, identified by its hint.
+func NewBundle(envelope Envelope, keyID string) Bundle {
+	return Bundle{
+		MediaType: BundleMediaType,
+		VerificationMaterial: VerificationMaterial{
+			PublicKey:   &PublicKeyIdentifier{Hint: keyID},
+			TlogEntries: []any{},
+		},
+		DsseEnvelope: &envelope,
+	}
+}
@@ -0,0 +1,150 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{name: "ecdsa p256", key: p256},
+		{name: "ecdsa p384", key: p384},
+		{name: "ed25519", key: edKey},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			signer, err := NewSigner(tt.key)
+			if err != nil {
+				t.Fatalf("failed to create signer: %v", err)
+			}
+			envelope, err := Sign(signer, PayloadType, []byte(testPayload))
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			content, err := json.Marshal(NewBundle(*envelope, signer.KeyID()))
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+
+			verifier, err := NewVerifier([]TrustRoot{
+				{ID: "key", PublicKey: publicKeyPEM(t, signer.PublicKey())},
+			})
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			payload, id, err := verifier.Verify(content)
+			if err != nil {
+				t.Fatalf("failed to verify: %v", err)
+			}
+			if diff := cmp.Diff([]string{"key", testPayload}, []string{id, string(payload)}); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_LoadSigner(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	pkcs8 := func(key any) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("failed to marshal key: %v", err)
+		}
+		return der
+	}
+
+	tests := []struct {
+		name     string
+		block    *pem.Block
+		expected bool
+	}{
+		{
+			name:     "ecdsa sec1",
+			block:    &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1},
+			expected: true,
+		},
+		{
+			name:     "ecdsa pkcs8",
+			block:    &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(ecKey)},
+			expected: true,
+		},
+		{
+			name:     "ed25519 pkcs8",
+			block:    &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(edKey)},
+			expected: true,
+		},
+		{
+			name:  "rsa",
+			block: &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8(rsaKey)},
+		},
+		{
+			name:  "public key",
+			block: &pem.Block{Type: "PUBLIC KEY", Bytes: sec1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "key.pem")
+			if err := os.WriteFile(path, pem.EncodeToMemory(tt.block), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			_, err := LoadSigner(path)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers the hash for crypto.SHA256.
+	_ "crypto/sha512" // Registers the hashes for crypto.SHA384 and crypto.SHA512.
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// TrustRoot is a trusted signer of attestations. It is either a public
+// key, or a certificate authority with the identity and issuer expected
+// in the certificates it issues, as with Fulcio.
+type TrustRoot struct {
+	ID string `json:"id"`
+	// PublicKey is a PEM-encoded public key.
+	PublicKey string `json:"public_key"`
+	// CACertificates are PEM-encoded CA certificates. The first one is
+	// the root, the others are intermediates.
+	CACertificates string `json:"ca_certificates"`
+	// Identity is the expected subject alternative name of the certificate.
+	Identity string `json:"identity"`
+	// Issuer is the expected OIDC issuer of the certificate.
+	Issuer string `json:"issuer"`
+}
+
+// OIDs of the Fulcio certificate extensions that contain the OIDC issuer.
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+type keyRoot struct {
+	id  string
+	key crypto.PublicKey
+}
+
+type caRoot struct {
+	id            string
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	identity      string
+	issuer        string
+}
+
+// Verifier verifies envelopes against a set of trust roots.
+type Verifier struct {
+	keys []keyRoot
+	cas  []caRoot
+}
+
+// NewVerifier creates a verifier for the trust roots.
+func NewVerifier(roots []TrustRoot) (*Verifier, error) {
+	if len(roots) == 0 {
+		return nil, errors.New("no trust roots")
+	}
+	var v Verifier
+	for i := range roots {
+		root := &roots[i]
+		if root.ID == "" {
+			return nil, fmt.Errorf("trust root %d: empty %q", i, "id")
+		}
+		switch {
+		case root.PublicKey != "" && root.CACertificates == "":
+			if root.Identity != "" || root.Issuer != "" {
+				return nil, fmt.Errorf("trust root %q: unexpected identity for a public key", root.ID)
+			}
+			key, err := parsePublicKey(root.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("trust root %q: %w", root.ID, err)
+			}
+			v.keys = append(v.keys, keyRoot{id: root.ID, key: key})
+		case root.CACertificates != "" && root.PublicKey == "":
+			ca, err := parseCARoot(*root)
+			if err != nil {
+				return nil, fmt.Errorf("trust root %q: %w", root.ID, err)
+			}
+			v.cas = append(v.cas, *ca)
+		default:
+			return nil, fmt.Errorf("trust root %q: one of %q or %q is required", root.ID, "public_key", "ca_certificates")
+		}
+	}
+	return &v, nil
+}
+
+func parsePublicKey(content string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(content))
+	if block == nil {
+		return nil, errors.New("invalid PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return key, nil
+}
+
+func parseCARoot(root TrustRoot) (*caRoot, error) {
+	if root.Identity == "" || root.Issuer == "" {
+		return nil, fmt.Errorf("%q and %q are required", "identity", "issuer")
+	}
+	certs, err := parseCertificates([]byte(root.CACertificates))
+	if err != nil {
+		return nil, err
+	}
+	ca := caRoot{
+		id:            root.ID,
+		roots:         x509.NewCertPool(),
+		intermediates: x509.NewCertPool(),
+		identity:      root.Identity,
+		issuer:        root.Issuer,
+	}
+	ca.roots.AddCert(certs[0])
+	for _, cert := range certs[1:] {
+		ca.intermediates.AddCert(cert)
+	}
+	return &ca, nil
+}
+
+func parseCertificates(content []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate")
+	}
+	return certs, nil
+}
+
+// Verify verifies a DSSE envelope, or a Sigstore bundle that contains
+// one, and returns its payload and the ID of the trust root that signed it.
+// Plain envelopes can only be verified with public keys.
+func (v *Verifier) Verify(content []byte) ([]byte, string, error) {
+	envelope, bundle, err := Parse(content)
+	if err != nil {
+		return nil, "", err
+	}
+	if bundle != nil {
+		return v.VerifyBundle(*bundle)
+	}
+	return v.VerifyEnvelope(*envelope)
+}
+
+// VerifyEnvelope verifies the envelope with the public keys of the
+// trust roots and returns its payload and the ID of the trust root.
+func (v *Verifier) VerifyEnvelope(envelope Envelope) ([]byte, string, error) {
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return nil, "", err
+	}
+	pae := PAE(envelope.PayloadType, payload)
+	for i := range v.keys {
+		if verifyAny(v.keys[i].key, pae, envelope.Signatures) == nil {
+			return payload, v.keys[i].id, nil
+		}
+	}
+	return nil, "", errors.New("no signature from a trusted key")
+}
+
+// VerifyBundle verifies the envelope of the bundle with its certificate
+// or with the public keys of the trust roots.
+// NOTE: there is no transparency log offline, so the certificate is
+// verified at the time it was issued: its short validity cannot be enforced.
+func (v *Verifier) VerifyBundle(bundle Bundle) ([]byte, string, error) {
+	if bundle.DsseEnvelope == nil {
+		return nil, "", errors.New("bundle: no DSSE envelope")
+	}
+	chain, err := bundleCertificates(bundle.VerificationMaterial)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(chain) == 0 {
+		return v.VerifyEnvelope(*bundle.DsseEnvelope)
+	}
+
+	envelope := bundle.DsseEnvelope
+	payload, err := envelope.DecodePayload()
+	if err != nil {
+		return nil, "", err
+	}
+	leaf := chain[0]
+	if err := verifyAny(leaf.PublicKey, PAE(envelope.PayloadType, payload), envelope.Signatures); err != nil {
+		return nil, "", fmt.Errorf("bundle: %w", err)
+	}
+	var errs []error
+	for i := range v.cas {
+		ca := &v.cas[i]
+		if err := ca.verifyCertificate(leaf, chain[1:]); err != nil {
+			errs = append(errs, fmt.Errorf("trust root %q: %w", ca.id, err))
+			continue
+		}
+		return payload, ca.id, nil
+	}
+	if len(errs) == 0 {
+		return nil, "", errors.New("bundle: no trusted certificate authority")
+	}
+	return nil, "", fmt.Errorf("bundle: %w", errors.Join(errs...))
+}
+
+func bundleCertificates(material VerificationMaterial) ([]*x509.Certificate, error) {
+	var raws []X509Certificate
+	if material.Certificate != nil {
+		raws = append(raws, *material.Certificate)
+	}
+	if material.X509CertificateChain != nil {
+		raws = append(raws, material.X509CertificateChain.Certificates...)
+	}
+	certs := make([]*x509.Certificate, 0, len(raws))
+	for i := range raws {
+		der, err := base64.StdEncoding.DecodeString(raws[i].RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: failed to decode certificate: %w", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+func (ca *caRoot) verifyCertificate(leaf *x509.Certificate, chain []*x509.Certificate) error {
+	intermediates := ca.intermediates.Clone()
+	for _, cert := range chain {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         ca.roots,
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify certificate: %w", err)
+	}
+	if !hasIdentity(leaf, ca.identity) {
+		return fmt.Errorf("certificate identity mismatch: expected %q", ca.identity)
+	}
+	issuer, err := certificateIssuer(leaf)
+	if err != nil {
+		return err
+	}
+	if issuer != ca.issuer {
+		return fmt.Errorf("certificate issuer mismatch: %q", issuer)
+	}
+	return nil
+}
+
+func hasIdentity(cert *x509.Certificate, identity string) bool {
+	for _, uri := range cert.URIs {
+		if uri.String() == identity {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateIssuer returns the OIDC issuer of a Fulcio certificate.
+func certificateIssuer(cert *x509.Certificate) (string, error) {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err != nil {
+				return "", fmt.Errorf("failed to parse issuer: %w", err)
+			}
+			return issuer, nil
+		case ext.Id.Equal(oidIssuer):
+			// The legacy extension contains the raw issuer.
+			return string(ext.Value), nil
+		}
+	}
+	return "", errors.New("certificate has no issuer")
+}
+
+// verifyAny returns nil if one of the signatures is valid for key.
+func verifyAny(key crypto.PublicKey, message []byte, signatures []Signature) error {
+	if len(signatures) == 0 {
+		return errors.New("no signatures")
+	}
+	for i := range signatures {
+		sig, err := base64.StdEncoding.DecodeString(signatures[i].Sig)
+		if err != nil {
+			continue
+		}
+		if verifySignature(key, message, sig) == nil {
+			return nil
+		}
+	}
+	return errors.New("invalid signatures")
+}
+
+func verifySignature(key crypto.PublicKey, message, sig []byte) error {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, message, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		hash := ecdsaHash(k.Curve)
+		if !ecdsa.VerifyASN1(k, digest(hash, message), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest(crypto.SHA256, message), sig)
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// ecdsaHash returns the hash used with the curve.
+func ecdsaHash(curve elliptic.Curve) crypto.Hash {
+	switch curve {
+	case elliptic.P384():
+		return crypto.SHA384
+	case elliptic.P521():
+		return crypto.SHA512
+	default:
+		return crypto.SHA256
+	}
+}
+
+func digest(hash crypto.Hash, message []byte) []byte {
+	h := hash.New()
+	h.Write(message)
+	return h.Sum(nil)
+}
@@ -0,0 +1,312 @@
+package dsse
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testPayload  = `{"_type": "https://in-toto.io/Statement/v1"}`
+	testIdentity = "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main"
+	testIssuer   = "https://token.actions.githubusercontent.com"
+)
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signEnvelope(t *testing.T, key crypto.Signer, payload string) Envelope {
+	t.Helper()
+	message := PAE(PayloadType, []byte(payload))
+	var sig []byte
+	var err error
+	switch key.(type) {
+	case ed25519.PrivateKey:
+		sig, err = key.Sign(rand.Reader, message, crypto.Hash(0))
+	default:
+		sig, err = key.Sign(rand.Reader, digest(crypto.SHA256, message), crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString([]byte(payload)),
+		Signatures:  []Signature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}
+}
+
+// testCA is a certificate authority that issues Fulcio-style certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+}
+
+// issue returns a short-lived certificate for identity and issuer.
+func (ca testCA) issue(t *testing.T, key crypto.PublicKey, identity, issuer string) []byte {
+	t.Helper()
+	uri, err := url.Parse(identity)
+	if err != nil {
+		t.Fatalf("failed to parse identity: %v", err)
+	}
+	issuerValue, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatalf("failed to marshal issuer: %v", err)
+	}
+	// The certificate expired, as Fulcio certificates do after 10 minutes.
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       time.Now().Add(-30 * time.Minute),
+		NotAfter:        time.Now().Add(-20 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func Test_Verifier(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	keyRoots := []TrustRoot{
+		{ID: "ecdsa", PublicKey: publicKeyPEM(t, &ecKey.PublicKey)},
+		{ID: "ed25519", PublicKey: publicKeyPEM(t, edKey.Public())},
+	}
+	caRoots := []TrustRoot{
+		{ID: "fulcio", CACertificates: ca.pem(), Identity: testIdentity, Issuer: testIssuer},
+	}
+	bundle := func(envelope Envelope, cert []byte) []byte {
+		b := Bundle{
+			MediaType: BundleMediaType,
+			VerificationMaterial: VerificationMaterial{
+				X509CertificateChain: &X509CertificateChain{
+					Certificates: []X509Certificate{{RawBytes: base64.StdEncoding.EncodeToString(cert)}},
+				},
+			},
+			DsseEnvelope: &envelope,
+		}
+		content, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return content
+	}
+	envelope := func(envelope Envelope) []byte {
+		content, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("failed to marshal: %v", err)
+		}
+		return content
+	}
+
+	tests := []struct {
+		name     string
+		roots    []TrustRoot
+		content  []byte
+		expected string
+	}{
+		{
+			name:     "ecdsa envelope",
+			roots:    keyRoots,
+			content:  envelope(signEnvelope(t, ecKey, testPayload)),
+			expected: "ecdsa",
+		},
+		{
+			name:     "ed25519 envelope",
+			roots:    keyRoots,
+			content:  envelope(signEnvelope(t, edKey, testPayload)),
+			expected: "ed25519",
+		},
+		{
+			name:    "untrusted key",
+			roots:   keyRoots,
+			content: envelope(signEnvelope(t, otherKey, testPayload)),
+		},
+		{
+			name:  "tampered payload",
+			roots: keyRoots,
+			content: func() []byte {
+				e := signEnvelope(t, ecKey, testPayload)
+				e.Payload = base64.StdEncoding.EncodeToString([]byte(`{}`))
+				return envelope(e)
+			}(),
+		},
+		{
+			name:     "bundle",
+			roots:    caRoots,
+			content:  bundle(signEnvelope(t, ecKey, testPayload), ca.issue(t, &ecKey.PublicKey, testIdentity, testIssuer)),
+			expected: "fulcio",
+		},
+		{
+			name:    "bundle identity mismatch",
+			roots:   caRoots,
+			content: bundle(signEnvelope(t, ecKey, testPayload), ca.issue(t, &ecKey.PublicKey, "https://github.com/other/repo", testIssuer)),
+		},
+		{
+			name:    "bundle issuer mismatch",
+			roots:   caRoots,
+			content: bundle(signEnvelope(t, ecKey, testPayload), ca.issue(t, &ecKey.PublicKey, testIdentity, "https://accounts.google.com")),
+		},
+		{
+			name:    "bundle untrusted CA",
+			roots:   caRoots,
+			content: bundle(signEnvelope(t, ecKey, testPayload), otherCA.issue(t, &ecKey.PublicKey, testIdentity, testIssuer)),
+		},
+		{
+			name:    "bundle signed by another key",
+			roots:   caRoots,
+			content: bundle(signEnvelope(t, otherKey, testPayload), ca.issue(t, &ecKey.PublicKey, testIdentity, testIssuer)),
+		},
+		{
+			name:    "envelope without a trusted key",
+			roots:   caRoots,
+			content: envelope(signEnvelope(t, ecKey, testPayload)),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			verifier, err := NewVerifier(tt.roots)
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			payload, id, err := verifier.Verify(tt.content)
+			if diff := cmp.Diff(tt.expected == "", err != nil); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, id); diff != "" {
+				t.Fatalf("unexpected trust root (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(testPayload, string(payload)); diff != "" {
+				t.Fatalf("unexpected payload (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_NewVerifier(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	tests := []struct {
+		name     string
+		roots    []TrustRoot
+		expected bool
+	}{
+		{
+			name:     "no roots",
+			expected: false,
+		},
+		{
+			name:     "no ID",
+			roots:    []TrustRoot{{CACertificates: ca.pem(), Identity: testIdentity, Issuer: testIssuer}},
+			expected: false,
+		},
+		{
+			name:     "invalid public key",
+			roots:    []TrustRoot{{ID: "key", PublicKey: "key"}},
+			expected: false,
+		},
+		{
+			name:     "no identity",
+			roots:    []TrustRoot{{ID: "fulcio", CACertificates: ca.pem(), Issuer: testIssuer}},
+			expected: false,
+		},
+		{
+			name:     "key and CA",
+			roots:    []TrustRoot{{ID: "both", PublicKey: "key", CACertificates: ca.pem()}},
+			expected: false,
+		},
+		{
+			name:     "valid CA",
+			roots:    []TrustRoot{{ID: "fulcio", CACertificates: ca.pem(), Identity: testIdentity, Issuer: testIssuer}},
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewVerifier(tt.roots)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package imageref canonicalizes container image references, so that
+// the spellings of the same image compare equal: nginx, docker://nginx
+// and docker.io/library/nginx are all docker.io/library/nginx.
+package imageref
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultRegistry is the registry of references without a registry.
+	DefaultRegistry = "docker.io"
+	// officialPrefix is the namespace of the official images
+	// of the default registry.
+	officialPrefix = "library/"
+	dockerScheme   = "docker://"
+	// globChars are the characters of the glob syntaxes of the policies.
+	globChars = "*?[]{}\\"
+)
+
+// registryAliases are the other names of the default registry.
+var registryAliases = map[string]bool{
+	"index.docker.io":      true,
+	"registry-1.docker.io": true,
+}
+
+var (
+	tagRegexp    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+)
+
+// Reference is a canonical image reference.
+type Reference struct {
+	// Registry is the lowercase registry, e.g. docker.io.
+	Registry string
+	// Repository is the path of the image in the registry,
+	// e.g. library/nginx.
+	Repository string
+	// Tag is the tag, if any.
+	Tag string
+	// Digest is the digest of the form alg:value, if any.
+	Digest string
+}
+
+// Parse parses an image reference of the form
+// [docker://][registry/]repository[:tag][@alg:value].
+func Parse(ref string) (Reference, error) {
+	r := split(ref)
+	if r.Repository == "" {
+		return Reference{}, fmt.Errorf("image %q: empty repository", ref)
+	}
+	for _, component := range strings.Split(r.Repository, "/") {
+		if component == "" {
+			return Reference{}, fmt.Errorf("image %q: empty path component", ref)
+		}
+	}
+	if strings.ContainsAny(r.Registry+r.Repository, " \t\n@") {
+		return Reference{}, fmt.Errorf("image %q: invalid name", ref)
+	}
+	if r.Tag != "" && !tagRegexp.MatchString(r.Tag) {
+		return Reference{}, fmt.Errorf("image %q: invalid tag %q", ref, r.Tag)
+	}
+	if r.Digest != "" && !digestRegexp.MatchString(r.Digest) {
+		return Reference{}, fmt.Errorf("image %q: invalid digest %q", ref, r.Digest)
+	}
+	return r, nil
+}
+
+// Normalize returns the canonical form of an image reference.
+func Normalize(ref string) (string, error) {
+	r, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// NormalizePattern returns the canonical form of a pattern of image
+// references. Globs are kept as they are: a pattern whose first path
+// component has a glob is assumed to start with the registry, and
+// the official namespace is not added to a repository with a glob,
+// which may match other namespaces.
+func NormalizePattern(pattern string) string {
+	if pattern == "" || strings.Trim(pattern, "*") == "" {
+		return pattern
+	}
+	return split(pattern).String()
+}
+
+// split splits a reference into its parts without validating them.
+func split(ref string) Reference {
+	var r Reference
+	ref = strings.TrimPrefix(ref, dockerScheme)
+
+	if name, digest, ok := strings.Cut(ref, "@"); ok {
+		ref, r.Digest = name, digest
+	}
+	// The tag follows the last colon after the last slash: other
+	// colons separate the host and the port of the registry.
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, r.Tag = ref[:i], ref[i+1:]
+	}
+
+	registry, repository, ok := strings.Cut(ref, "/")
+	if ok && isRegistry(registry) {
+		r.Registry, r.Repository = strings.ToLower(registry), repository
+	} else {
+		r.Registry, r.Repository = DefaultRegistry, ref
+	}
+	if registryAliases[r.Registry] {
+		r.Registry = DefaultRegistry
+	}
+	if r.Registry == DefaultRegistry && r.Repository != "" && !strings.ContainsAny(r.Repository, "/"+globChars) {
+		r.Repository = officialPrefix + r.Repository
+	}
+	return r
+}
+
+// isRegistry returns true if the first path component of a reference
+// is a registry rather than a namespace, as Docker does.
+func isRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:*") || component == "localhost" ||
+		strings.ToLower(component) != component
+}
+
+// Name returns the registry and the repository.
+func (r Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the canonical form of the reference.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// WithoutDigest returns the reference without its digest.
+func (r Reference) WithoutDigest() Reference {
+	r.Digest = ""
+	return r
+}
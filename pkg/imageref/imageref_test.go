@@ -0,0 +1,117 @@
+package imageref
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDigest = "sha256:2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a"
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ref      string
+		expected *Reference
+	}{
+		{
+			ref:      "nginx",
+			expected: &Reference{Registry: "docker.io", Repository: "library/nginx"},
+		},
+		{
+			ref:      "docker://nginx",
+			expected: &Reference{Registry: "docker.io", Repository: "library/nginx"},
+		},
+		{
+			ref:      "docker.io/library/nginx",
+			expected: &Reference{Registry: "docker.io", Repository: "library/nginx"},
+		},
+		{
+			ref:      "index.docker.io/library/nginx:1.25",
+			expected: &Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "1.25"},
+		},
+		{
+			ref:      "laurentsimon/slsa-project:v1.2.3@" + testDigest,
+			expected: &Reference{Registry: "docker.io", Repository: "laurentsimon/slsa-project", Tag: "v1.2.3", Digest: testDigest},
+		},
+		{
+			ref:      "GHCR.io/laurentsimon/echo-server@" + testDigest,
+			expected: &Reference{Registry: "ghcr.io", Repository: "laurentsimon/echo-server", Digest: testDigest},
+		},
+		{
+			ref:      "localhost:5000/org/image:latest",
+			expected: &Reference{Registry: "localhost:5000", Repository: "org/image", Tag: "latest"},
+		},
+		{
+			ref:      "localhost/image",
+			expected: &Reference{Registry: "localhost", Repository: "image"},
+		},
+		{
+			ref:      "us-west2-docker.pkg.dev/project/repo/image",
+			expected: &Reference{Registry: "us-west2-docker.pkg.dev", Repository: "project/repo/image"},
+		},
+		{ref: ""},
+		{ref: "docker://"},
+		{ref: "ghcr.io/"},
+		{ref: "org//image"},
+		{ref: "image:-tag"},
+		{ref: "image@sha256"},
+		{ref: "image name"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.ref)
+		if diff := cmp.Diff(tt.expected != nil, err == nil); diff != "" {
+			t.Errorf("%q: unexpected error (-want +got): %v\n%s", tt.ref, err, diff)
+			continue
+		}
+		if tt.expected != nil {
+			if diff := cmp.Diff(*tt.expected, r); diff != "" {
+				t.Errorf("%q: unexpected reference (-want +got): \n%s", tt.ref, diff)
+			}
+		}
+	}
+}
+
+func Test_Normalize(t *testing.T) {
+	t.Parallel()
+
+	for _, ref := range []string{"nginx", "docker://nginx", "docker.io/library/nginx", "registry-1.docker.io/nginx"} {
+		got, err := Normalize(ref)
+		if err != nil {
+			t.Errorf("%q: %v", ref, err)
+			continue
+		}
+		if diff := cmp.Diff("docker.io/library/nginx", got); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): \n%s", ref, diff)
+		}
+	}
+}
+
+func Test_NormalizePattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern, expected string
+	}{
+		{pattern: "*", expected: "*"},
+		{pattern: "", expected: ""},
+		{pattern: "ghcr.io/laurentsimon/*", expected: "ghcr.io/laurentsimon/*"},
+		{pattern: "docker://googlenot/*", expected: "docker.io/googlenot/*"},
+		{pattern: "docker://googlenot/myimage:v1.2.3", expected: "docker.io/googlenot/myimage:v1.2.3"},
+		{pattern: "nginx*", expected: "docker.io/nginx*"},
+		{pattern: "nginx", expected: "docker.io/library/nginx"},
+		{pattern: "docker.io/*", expected: "docker.io/*"},
+		{pattern: "docker.io/org*", expected: "docker.io/org*"},
+		{pattern: "docker.io/nginx?", expected: "docker.io/nginx?"},
+		{pattern: "docker.io/{nginx,redis}", expected: "docker.io/{nginx,redis}"},
+		{pattern: "docker.io/[a-z]*", expected: "docker.io/[a-z]*"},
+		{pattern: "*/org/image", expected: "*/org/image"},
+		{pattern: "GHCR.IO/Org/*", expected: "ghcr.io/Org/*"},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.expected, NormalizePattern(tt.pattern)); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): \n%s", tt.pattern, diff)
+		}
+	}
+}
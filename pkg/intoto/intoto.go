@@ -0,0 +1,62 @@
+// Package intoto defines in-toto attestation statements.
+package intoto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// StatementType is the type of in-toto v1 statements.
+const StatementType = "https://in-toto.io/Statement/v1"
+
+// ResourceDescriptor describes a resource, such as a subject or a policy.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// Statement is an in-toto statement. Its predicate is left to the caller.
+type Statement[P any] struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     P                    `json:"predicate"`
+}
+
+// SubjectFromImage returns the subject of an image of the form name@alg:digest.
+func SubjectFromImage(imageURI string) (ResourceDescriptor, error) {
+	name, digest, ok := strings.Cut(imageURI, "@")
+	if !ok || name == "" {
+		return ResourceDescriptor{}, fmt.Errorf("image %q: no digest", imageURI)
+	}
+	alg, value, ok := strings.Cut(digest, ":")
+	if !ok || alg == "" || value == "" {
+		return ResourceDescriptor{}, fmt.Errorf("image %q: invalid digest", imageURI)
+	}
+	return ResourceDescriptor{
+		Name:   name,
+		Digest: map[string]string{alg: value},
+	}, nil
+}
+
+// HasDigest returns true if one of the subjects has the digest value for alg.
+func HasDigest(subjects []ResourceDescriptor, alg, value string) bool {
+	for i := range subjects {
+		if v, ok := subjects[i].Digest[alg]; ok && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Descriptor returns the descriptor of a resource with its SHA256 digest.
+func Descriptor(uri string, content []byte) ResourceDescriptor {
+	digest := sha256.Sum256(content)
+	return ResourceDescriptor{
+		URI:    uri,
+		Digest: map[string]string{"sha256": hex.EncodeToString(digest[:])},
+	}
+}
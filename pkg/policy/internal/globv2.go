@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// maxAlternatives bounds the number of patterns a pattern
+// with alternations expands to.
+const maxAlternatives = 256
+
+// GlobV2 tests a string pattern of version 2 against a subject string:
+//   - * matches any characters other than /
+//   - ** matches any characters, including /
+//   - ? matches one character other than /
+//   - [a-z] matches one character of a class other than /, and [!a-z] or
+//     [^a-z] one character outside of it
+//   - {a,b} matches one of the comma-separated patterns
+//   - \ escapes the next character
+//
+// Invalid patterns match nothing.
+func GlobV2(pattern, subj string) bool {
+	alternatives, err := parseGlobV2(pattern)
+	if err != nil {
+		return false
+	}
+	runes := []rune(subj)
+	for _, tokens := range alternatives {
+		if matchTokens(tokens, runes) {
+			return true
+		}
+	}
+	return false
+}
+
+type tokenKind int
+
+const (
+	// tokenLiteral matches a character.
+	tokenLiteral tokenKind = iota
+	// tokenAny matches a character other than /.
+	tokenAny
+	// tokenClass matches a character of a class other than /.
+	tokenClass
+	// tokenStar matches any characters other than /.
+	tokenStar
+	// tokenDoubleStar matches any characters.
+	tokenDoubleStar
+)
+
+type charRange struct {
+	lo, hi rune
+}
+
+type token struct {
+	kind tokenKind
+	// char is the character of a literal.
+	char rune
+	// ranges and negated define a class.
+	ranges  []charRange
+	negated bool
+}
+
+// matches returns true if the token matches the character c.
+// It must be a literal, any or class token.
+func (t token) matches(c rune) bool {
+	switch t.kind {
+	case tokenLiteral:
+		return t.char == c
+	case tokenAny:
+		return c != '/'
+	case tokenClass:
+		return c != '/' && t.inClass(c)
+	default:
+		return false
+	}
+}
+
+func (t token) inClass(c rune) bool {
+	in := false
+	for _, r := range t.ranges {
+		if r.lo <= c && c <= r.hi {
+			in = true
+			break
+		}
+	}
+	return in != t.negated
+}
+
+// matchTokens returns true if the tokens match the subject.
+func matchTokens(tokens []token, subj []rune) bool {
+	// matched[j] is true if the tokens processed so far match subj[:j].
+	matched := make([]bool, len(subj)+1)
+	matched[0] = true
+	for _, t := range tokens {
+		next := make([]bool, len(subj)+1)
+		for j := range matched {
+			if !matched[j] {
+				continue
+			}
+			switch t.kind {
+			case tokenStar, tokenDoubleStar:
+				for k := j; k <= len(subj); k++ {
+					next[k] = true
+					if k < len(subj) && t.kind == tokenStar && subj[k] == '/' {
+						break
+					}
+				}
+			default:
+				if j < len(subj) && t.matches(subj[j]) {
+					next[j+1] = true
+				}
+			}
+		}
+		matched = next
+	}
+	return matched[len(subj)]
+}
+
+// parseGlobV2 parses a pattern of version 2 into the token
+// sequences of its alternatives.
+func parseGlobV2(pattern string) ([][]token, error) {
+	p := globParser{pattern: pattern}
+	alternatives, err := p.sequence(false)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	return alternatives, nil
+}
+
+type globParser struct {
+	pattern string
+	pos     int
+}
+
+func (p *globParser) next() rune {
+	c, size := utf8.DecodeRuneInString(p.pattern[p.pos:])
+	p.pos += size
+	return c
+}
+
+// sequence parses tokens until the end of the pattern or, within
+// an alternation, until a , or a }.
+func (p *globParser) sequence(inAlternation bool) ([][]token, error) {
+	alternatives := [][]token{nil}
+	appendAll := func(suffixes [][]token) error {
+		if len(alternatives)*len(suffixes) > maxAlternatives {
+			return errors.New("too many alternatives")
+		}
+		var product [][]token
+		for _, prefix := range alternatives {
+			for _, suffix := range suffixes {
+				tokens := make([]token, 0, len(prefix)+len(suffix))
+				tokens = append(tokens, prefix...)
+				product = append(product, append(tokens, suffix...))
+			}
+		}
+		alternatives = product
+		return nil
+	}
+	for p.pos < len(p.pattern) {
+		c, _ := utf8.DecodeRuneInString(p.pattern[p.pos:])
+		if inAlternation && (c == ',' || c == '}') {
+			break
+		}
+		p.next()
+		var t token
+		switch c {
+		case '\\':
+			if p.pos == len(p.pattern) {
+				return nil, errors.New("trailing \\")
+			}
+			t = token{kind: tokenLiteral, char: p.next()}
+		case '?':
+			t = token{kind: tokenAny}
+		case '*':
+			t = token{kind: tokenStar}
+			if p.pos < len(p.pattern) && p.pattern[p.pos] == '*' {
+				p.pos++
+				t = token{kind: tokenDoubleStar}
+			}
+		case '[':
+			class, err := p.class()
+			if err != nil {
+				return nil, err
+			}
+			t = class
+		case '{':
+			choices, err := p.alternation()
+			if err != nil {
+				return nil, err
+			}
+			if err := appendAll(choices); err != nil {
+				return nil, err
+			}
+			continue
+		case ']', '}':
+			return nil, fmt.Errorf("unbalanced %q", c)
+		default:
+			t = token{kind: tokenLiteral, char: c}
+		}
+		for i := range alternatives {
+			alternatives[i] = append(alternatives[i], t)
+		}
+	}
+	return alternatives, nil
+}
+
+// alternation parses the comma-separated patterns following a {.
+func (p *globParser) alternation() ([][]token, error) {
+	var choices [][]token
+	for {
+		alternatives, err := p.sequence(true)
+		if err != nil {
+			return nil, err
+		}
+		choices = append(choices, alternatives...)
+		if len(choices) > maxAlternatives {
+			return nil, errors.New("too many alternatives")
+		}
+		if p.pos == len(p.pattern) {
+			return nil, errors.New("unterminated {")
+		}
+		if p.next() == '}' {
+			return choices, nil
+		}
+	}
+}
+
+// class parses the characters of a class following a [.
+func (p *globParser) class() (token, error) {
+	t := token{kind: tokenClass}
+	if p.pos < len(p.pattern) && (p.pattern[p.pos] == '!' || p.pattern[p.pos] == '^') {
+		t.negated = true
+		p.pos++
+	}
+	for {
+		if p.pos == len(p.pattern) {
+			return token{}, errors.New("unterminated [")
+		}
+		lo := p.next()
+		switch lo {
+		case ']':
+			if len(t.ranges) == 0 {
+				return token{}, errors.New("empty class")
+			}
+			return t, nil
+		case '\\':
+			if p.pos == len(p.pattern) {
+				return token{}, errors.New("unterminated [")
+			}
+			lo = p.next()
+		}
+		hi := lo
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos] == '-' && p.pattern[p.pos+1] != ']' {
+			p.pos++
+			hi = p.next()
+			if hi == '\\' && p.pos < len(p.pattern) {
+				hi = p.next()
+			}
+			if hi < lo {
+				return token{}, fmt.Errorf("invalid range %q-%q", lo, hi)
+			}
+		}
+		t.ranges = append(t.ranges, charRange{lo: lo, hi: hi})
+	}
+}
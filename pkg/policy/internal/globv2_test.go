@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGlobV2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern  string
+		matches  []string
+		excludes []string
+	}{
+		{
+			pattern:  "git+https://github.com/org/*",
+			matches:  []string{"git+https://github.com/org/repo", "git+https://github.com/org/"},
+			excludes: []string{"git+https://github.com/org/repo/evil/../path", "git+https://github.com/other/repo"},
+		},
+		{
+			pattern:  "ghcr.io/org/**",
+			matches:  []string{"ghcr.io/org/image", "ghcr.io/org/nested/image:v1"},
+			excludes: []string{"ghcr.io/other/image"},
+		},
+		{
+			pattern:  "ghcr.io/org/image-?",
+			matches:  []string{"ghcr.io/org/image-a"},
+			excludes: []string{"ghcr.io/org/image-", "ghcr.io/org/image-ab", "ghcr.io/org/image-/"},
+		},
+		{
+			pattern:  "v[0-9].[0-9]",
+			matches:  []string{"v1.2", "v9.0"},
+			excludes: []string{"va.2", "v10.2"},
+		},
+		{
+			pattern:  "[!a-z]*",
+			matches:  []string{"Repo", "1repo"},
+			excludes: []string{"repo", "/repo"},
+		},
+		{
+			pattern:  "git+https://github.com/{org,other}/{repo,cli-*}",
+			matches:  []string{"git+https://github.com/org/repo", "git+https://github.com/other/cli-go"},
+			excludes: []string{"git+https://github.com/org/web", "git+https://github.com/evil/repo"},
+		},
+		{
+			pattern:  "release/{v1,v2{,.*}}",
+			matches:  []string{"release/v1", "release/v2", "release/v2.5"},
+			excludes: []string{"release/v1.5", "release/v3"},
+		},
+		{
+			pattern:  `literal\*\{`,
+			matches:  []string{"literal*{"},
+			excludes: []string{"literalx{"},
+		},
+		{
+			pattern:  "",
+			matches:  []string{""},
+			excludes: []string{"x"},
+		},
+		{
+			pattern:  "*",
+			matches:  []string{"", "ϗѾ test"},
+			excludes: []string{"a/b"},
+		},
+	}
+	for _, tt := range tests {
+		for _, subj := range tt.matches {
+			if !GlobV2(tt.pattern, subj) {
+				t.Errorf("%s should match %s", tt.pattern, subj)
+			}
+		}
+		for _, subj := range tt.excludes {
+			if GlobV2(tt.pattern, subj) {
+				t.Errorf("%s should not match %s", tt.pattern, subj)
+			}
+		}
+	}
+}
+
+func Test_parseGlobV2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern  string
+		expected bool
+	}{
+		{pattern: "a{b,c}[d-f]?*/**", expected: true},
+		{pattern: "[]", expected: false},
+		{pattern: "[a-", expected: false},
+		{pattern: "[z-a]", expected: false},
+		{pattern: "{a,b", expected: false},
+		{pattern: "a}", expected: false},
+		{pattern: "a]", expected: false},
+		{pattern: `a\`, expected: false},
+		{pattern: strings.Repeat("{a,b}", 9), expected: false},
+	}
+	for _, tt := range tests {
+		_, err := parseGlobV2(tt.pattern)
+		if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): %v\n%s", tt.pattern, err, diff)
+		}
+	}
+}
+
+func BenchmarkGlobV2(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if !GlobV2("*quick*fox*dog", "The quick brown fox jumped over the lazy dog") {
+			b.Fatalf("should match")
+		}
+	}
+}
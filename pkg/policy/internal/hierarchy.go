@@ -0,0 +1,161 @@
+package internal
+
+import "fmt"
+
+// entries returns the defaults followed by the projects of a policy.
+func entries(p OrgPolicy) []Entry {
+	entries := []Entry{*p.Defaults}
+	return append(entries, p.Projects...)
+}
+
+// validateNarrowing verifies that every entry of the child level
+// is allowed by an entry of its parent level.
+func validateNarrowing(parent, child level) error {
+	parentEntries := entries(parent.policy)
+	childEntries := entries(child.policy)
+	c := coverage{parent: syntaxOf(parent.policy.Version), child: syntaxOf(child.policy.Version)}
+	for i := range childEntries {
+		if err := entryNarrows(c, parentEntries, childEntries[i]); err != nil {
+			return fmt.Errorf("%q policy: %s: %w of the %q policy", child.context, entryName(i), err, parent.context)
+		}
+	}
+	return nil
+}
+
+// entryNarrows verifies that each source of the child entry is allowed
+// by a parent entry whose images and tracks contain the child's.
+func entryNarrows(c coverage, parents []Entry, child Entry) error {
+	for i := range child.Sources {
+		source := child.Sources[i].URI
+		var err error
+		found := false
+		for j := range parents {
+			parent := &parents[j]
+			if !sourceCovered(c, parent.Sources, source) {
+				continue
+			}
+			if err = entryWithin(c, *parent, child); err == nil {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("source %q: %w", source, err)
+		}
+		return fmt.Errorf("source %q widens the sources", source)
+	}
+	return nil
+}
+
+// entryWithin verifies that the child entry does not widen the parent entry.
+// Empty lists in the child do not widen the parent: they are
+// restricted by the parent during evaluation. Label selectors are not
+// compared: they only restrict where an entry applies, and every level
+// must be satisfied during evaluation.
+func entryWithin(c coverage, parent, child Entry) error {
+	for i := range child.Images {
+		if !resourceCovered(c, parent.Images, child.Images[i].URI) {
+			return fmt.Errorf("image %q widens the images", child.Images[i].URI)
+		}
+	}
+	for i := range child.Tracks.Build.Builders {
+		b := &child.Tracks.Build.Builders[i]
+		if !builderCovered(c, parent.Tracks.Build.Builders, *b) {
+			return fmt.Errorf("builder %q (level %d) widens the builders", b.ID, b.Level)
+		}
+	}
+	for i := range child.Tracks.Source.Sourcers {
+		s := &child.Tracks.Source.Sourcers[i]
+		if !sourcerCovered(c, parent.Tracks.Source.Sourcers, *s) {
+			return fmt.Errorf("source attestor %q widens the attestors", s.ID)
+		}
+	}
+	if !refsWithin(c, child.Refs, parent.Refs) {
+		return fmt.Errorf("refs %q widen the refs %q", child.Refs, parent.Refs)
+	}
+	if child.RequireBuildLevel < parent.RequireBuildLevel {
+		return fmt.Errorf("require_build_level %d lowers the level %d", child.RequireBuildLevel, parent.RequireBuildLevel)
+	}
+	return nil
+}
+
+// refsWithin returns true if the child refs do not widen the parent refs.
+// Refs that are not set do not widen the parent: they are restricted
+// by the parent during evaluation.
+func refsWithin(c coverage, child, parent *Refs) bool {
+	return child == nil || parent == nil || child.within(c, parent)
+}
+
+func resourceCovered(c coverage, parents []Resource, uri Pattern) bool {
+	if len(parents) == 0 {
+		return true
+	}
+	for i := range parents {
+		if coversPattern(c, parents[i].URI, uri, coverage.covers) {
+			return true
+		}
+	}
+	return false
+}
+
+func builderCovered(c coverage, parents []Builder, child Builder) bool {
+	if len(parents) == 0 {
+		return true
+	}
+	for i := range parents {
+		parent := &parents[i]
+		if coversPattern(c, parent.ID, child.ID, coverage.covers) && child.Level <= parent.Level {
+			return true
+		}
+	}
+	return false
+}
+
+func sourcerCovered(c coverage, parents []Sourcer, child Sourcer) bool {
+	if len(parents) == 0 {
+		return true
+	}
+	for i := range parents {
+		parent := &parents[i]
+		if !coversPattern(c, parent.ID, child.ID, coverage.covers) {
+			continue
+		}
+		if parent.Level == 0 || (child.Level > 0 && child.Level <= parent.Level) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRepoNarrowing verifies that every project of the repo policy
+// is allowed by an entry of its parent level.
+func validateRepoNarrowing(parent level, repo RepoPolicy) error {
+	parentEntries := entries(parent.policy)
+	c := coverage{parent: syntaxOf(parent.policy.Version), child: syntaxOf(repo.Version)}
+	for i := range repo.Projects {
+		project := &repo.Projects[i]
+		found := false
+		for j := range parentEntries {
+			entry := &parentEntries[j]
+			if !sourceCovered(c, entry.Sources, project.Source.URI) {
+				continue
+			}
+			if !project.Image.URI.empty() && !resourceCovered(c, entry.Images, project.Image.URI) {
+				continue
+			}
+			if !refsWithin(c, project.Refs, entry.Refs) {
+				continue
+			}
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("%q policy: project %d: source %q and image %q widen the %q policy",
+				contextRepo, i, project.Source.URI, project.Image.URI, parent.context)
+		}
+	}
+	return nil
+}
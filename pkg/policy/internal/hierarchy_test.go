@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+const testHierarchyOrg = `{
+    "version": 1,
+    "type": "org",
+    "defaults": {
+        "tracks": {
+            "build": {
+                "builders": [
+                    {"id": "https://github.com/builder-l2", "level": 2},
+                    {"id": "https://github.com/builder-l3", "level": 3}
+                ]
+            }
+        },
+        "images": [{"uri": "docker://org/*"}],
+        "sources": [{"uri": "git+https://github.com/org/*"}]
+    }
+}`
+
+const testHierarchyUnit = `{
+    "version": 1,
+    "type": "unit",
+    "defaults": {
+        "tracks": {
+            "build": {
+                "builders": [{"id": "https://github.com/builder-l3", "level": 3}]
+            }
+        },
+        "images": [{"uri": "docker://org/unit-*"}],
+        "sources": [{"uri": "git+https://github.com/org/unit-*"}]
+    }
+}`
+
+const testHierarchyTeam = `{
+    "version": 1,
+    "type": "team",
+    "defaults": {
+        "sources": [{"uri": "git+https://github.com/org/unit-team-*"}]
+    }
+}`
+
+const testHierarchyRepo = `{
+    "version": 1,
+    "type": "repo",
+    "projects": [
+        {
+            "source": {"uri": "git+https://github.com/org/unit-team-repo"},
+            "image": {"uri": "docker://org/unit-image"}
+        }
+    ]
+}`
+
+func Test_FromBytes_hierarchy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policies []string
+		expected bool
+	}{
+		{
+			name:     "org only",
+			policies: []string{testHierarchyOrg},
+			expected: true,
+		},
+		{
+			name:     "org and repo without types",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`, `{"version": 1}`},
+			expected: true,
+		},
+		{
+			name:     "four levels",
+			policies: []string{testHierarchyOrg, testHierarchyUnit, testHierarchyTeam, testHierarchyRepo},
+			expected: true,
+		},
+		{
+			name:     "no policies",
+			expected: false,
+		},
+		{
+			name:     "missing intermediate type",
+			policies: []string{testHierarchyOrg, `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`, testHierarchyRepo},
+			expected: false,
+		},
+		{
+			name:     "org not first",
+			policies: []string{testHierarchyUnit, testHierarchyOrg},
+			expected: false,
+		},
+		{
+			name:     "repo not last",
+			policies: []string{testHierarchyOrg, testHierarchyRepo, testHierarchyUnit},
+			expected: false,
+		},
+		{
+			name:     "unit after team",
+			policies: []string{testHierarchyOrg, testHierarchyTeam, testHierarchyUnit},
+			expected: false,
+		},
+		{
+			name: "intermediate level with enforcement",
+			policies: []string{testHierarchyOrg, `{
+				"version": 1, "type": "unit",
+				"defaults": {"sources": [{"uri": "git+https://github.com/org/unit-*"}]},
+				"enforcement": {"onViolation": "allow", "overwrite": {"default": "allow"}}
+			}`},
+			expected: false,
+		},
+		{
+			name: "widen sources",
+			policies: []string{testHierarchyOrg, `{
+				"version": 1, "type": "unit",
+				"defaults": {"sources": [{"uri": "git+https://github.com/*"}]}
+			}`},
+			expected: false,
+		},
+		{
+			name: "widen images",
+			policies: []string{testHierarchyOrg, `{
+				"version": 1, "type": "unit",
+				"defaults": {"sources": [{"uri": "git+https://github.com/org/unit-*"}], "images": [{"uri": "docker://other/*"}]}
+			}`},
+			expected: false,
+		},
+		{
+			name: "widen builders",
+			policies: []string{testHierarchyOrg, `{
+				"version": 1, "type": "unit",
+				"defaults": {
+					"sources": [{"uri": "git+https://github.com/org/unit-*"}],
+					"tracks": {"build": {"builders": [{"id": "https://github.com/other-builder", "level": 3}]}}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "raise builder level",
+			policies: []string{testHierarchyOrg, `{
+				"version": 1, "type": "unit",
+				"defaults": {
+					"sources": [{"uri": "git+https://github.com/org/unit-*"}],
+					"tracks": {"build": {"builders": [{"id": "https://github.com/builder-l2", "level": 3}]}}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "widen project sources",
+			policies: []string{testHierarchyOrg, testHierarchyUnit, `{
+				"version": 1, "type": "team",
+				"defaults": {"sources": [{"uri": "git+https://github.com/org/unit-team-*"}]},
+				"projects": [{"sources": [{"uri": "git+https://github.com/org/other"}]}]
+			}`},
+			expected: false,
+		},
+		{
+			name: "widen repo",
+			policies: []string{testHierarchyOrg, testHierarchyUnit, testHierarchyTeam, `{
+				"version": 1, "type": "repo",
+				"projects": [{"source": {"uri": "git+https://github.com/org/other"}}]
+			}`},
+			expected: false,
+		},
+		{
+			name: "repo in another spelling",
+			policies: []string{testHierarchyOrg, testHierarchyUnit, testHierarchyTeam, `{
+				"version": 1, "type": "repo",
+				"projects": [{"source": {"uri": "https://github.com/org/unit-team-repo.git"}}]
+			}`},
+			expected: true,
+		},
+		{
+			name: "narrow the ref",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "github.com/org/*@refs/heads/*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo@refs/heads/main"}}]}`,
+			},
+			expected: true,
+		},
+		{
+			name: "drop the ref",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "github.com/org/*@refs/heads/*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}}]}`,
+			},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := make([][]byte, len(tt.policies))
+			for i := range tt.policies {
+				content[i] = []byte(tt.policies[i])
+			}
+			_, err := FromBytes(content)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_hierarchy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		sourceURI string
+		imageURI  string
+		builderID string
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "pass",
+			sourceURI: "git+https://github.com/org/unit-team-repo",
+			imageURI:  "docker://org/unit-image",
+			builderID: "https://github.com/builder-l3",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "builder allowed by org but not unit",
+			sourceURI: "git+https://github.com/org/unit-team-repo",
+			imageURI:  "docker://org/unit-image",
+			builderID: "https://github.com/builder-l2",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "source allowed by unit but not team",
+			sourceURI: "git+https://github.com/org/unit-repo",
+			imageURI:  "docker://org/unit-image",
+			builderID: "https://github.com/builder-l3",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "image allowed by org but not unit",
+			sourceURI: "git+https://github.com/org/unit-team-repo",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder-l3",
+			expected:  results.Verification.Fail,
+		},
+	}
+	content := [][]byte{
+		[]byte(testHierarchyOrg), []byte(testHierarchyUnit),
+		[]byte(testHierarchyTeam), []byte(testHierarchyRepo),
+	}
+	policy, err := FromBytes(content)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate(tt.sourceURI, tt.imageURI, tt.builderID)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Operator defines how a label requirement is matched.
+type Operator string
+
+const (
+	// OperatorIn requires the label to be one of the values.
+	OperatorIn = "in"
+	// OperatorNotIn requires the label to be absent or none of the values.
+	OperatorNotIn = "not_in"
+	// OperatorExists requires the label to be set.
+	OperatorExists = "exists"
+	// OperatorNotExists requires the label to be absent.
+	OperatorNotExists = "not_exists"
+)
+
+// LabelRequirement is a requirement on the value of a label.
+type LabelRequirement struct {
+	Key      string   `json:"key"`
+	Operator Operator `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+// LabelSelector restricts an entry to evaluations whose labels
+// match every label of Match and every requirement of Expressions.
+type LabelSelector struct {
+	Match       map[string]string  `json:"match"`
+	Expressions []LabelRequirement `json:"expressions"`
+}
+
+func validateLabelSelector(selector *LabelSelector) error {
+	if selector == nil {
+		return nil
+	}
+	for key := range selector.Match {
+		if key == "" {
+			return fmt.Errorf("empty %q", "labels.match key")
+		}
+	}
+	for i := range selector.Expressions {
+		requirement := &selector.Expressions[i]
+		if requirement.Key == "" {
+			return fmt.Errorf("labels expression %d: empty %q", i, "key")
+		}
+		switch requirement.Operator {
+		case OperatorIn, OperatorNotIn:
+			if len(requirement.Values) == 0 {
+				return fmt.Errorf("labels expression %d: empty %q", i, "values")
+			}
+		case OperatorExists, OperatorNotExists:
+			if len(requirement.Values) != 0 {
+				return fmt.Errorf("labels expression %d: unexpected %q", i, "values")
+			}
+		default:
+			return fmt.Errorf("labels expression %d: invalid %q: %q", i, "operator", requirement.Operator)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the labels satisfy the selector.
+// A nil selector matches any labels.
+func (s *LabelSelector) matches(labels map[string]string) bool {
+	if s == nil {
+		return true
+	}
+	for key, value := range s.Match {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	for i := range s.Expressions {
+		if !s.Expressions[i].matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *LabelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case OperatorIn:
+		return ok && contains(r.Values, value)
+	case OperatorNotIn:
+		return !ok || !contains(r.Values, value)
+	case OperatorExists:
+		return ok
+	case OperatorNotExists:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the selector in a form similar to Kubernetes label
+// selectors, e.g. "tier=prod,namespace in (a,b)".
+func (s *LabelSelector) String() string {
+	if s == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(s.Match)+len(s.Expressions))
+	for key, value := range s.Match {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	for i := range s.Expressions {
+		r := &s.Expressions[i]
+		switch r.Operator {
+		case OperatorExists:
+			parts = append(parts, r.Key)
+		case OperatorNotExists:
+			parts = append(parts, "!"+r.Key)
+		default:
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", r.Key, strings.ReplaceAll(string(r.Operator), "_", ""), strings.Join(r.Values, ",")))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatLabels returns the labels sorted by key, e.g. "namespace=a,tier=prod".
+func formatLabels(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for key, value := range labels {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
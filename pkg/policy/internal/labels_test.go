@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_LabelSelector_matches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		selector *LabelSelector
+		labels   map[string]string
+		expected bool
+	}{
+		{
+			name:     "nil selector",
+			labels:   map[string]string{"tier": "prod"},
+			expected: true,
+		},
+		{
+			name:     "equality",
+			selector: &LabelSelector{Match: map[string]string{"tier": "prod"}},
+			labels:   map[string]string{"tier": "prod", "namespace": "a"},
+			expected: true,
+		},
+		{
+			name:     "equality mismatch",
+			selector: &LabelSelector{Match: map[string]string{"tier": "prod"}},
+			labels:   map[string]string{"tier": "dev"},
+			expected: false,
+		},
+		{
+			name:     "equality missing",
+			selector: &LabelSelector{Match: map[string]string{"tier": ""}},
+			expected: false,
+		},
+		{
+			name: "in",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "namespace", Operator: OperatorIn, Values: []string{"a", "b"}},
+			}},
+			labels:   map[string]string{"namespace": "b"},
+			expected: true,
+		},
+		{
+			name: "in missing",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "namespace", Operator: OperatorIn, Values: []string{"a", "b"}},
+			}},
+			expected: false,
+		},
+		{
+			name: "not in",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "namespace", Operator: OperatorNotIn, Values: []string{"a", "b"}},
+			}},
+			labels:   map[string]string{"namespace": "b"},
+			expected: false,
+		},
+		{
+			name: "not in missing",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "namespace", Operator: OperatorNotIn, Values: []string{"a", "b"}},
+			}},
+			expected: true,
+		},
+		{
+			name: "exists",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "tier", Operator: OperatorExists},
+			}},
+			labels:   map[string]string{"tier": ""},
+			expected: true,
+		},
+		{
+			name: "not exists",
+			selector: &LabelSelector{Expressions: []LabelRequirement{
+				{Key: "tier", Operator: OperatorNotExists},
+			}},
+			labels:   map[string]string{"tier": "prod"},
+			expected: false,
+		},
+		{
+			name: "all requirements",
+			selector: &LabelSelector{
+				Match: map[string]string{"tier": "prod"},
+				Expressions: []LabelRequirement{
+					{Key: "namespace", Operator: OperatorExists},
+				},
+			},
+			labels:   map[string]string{"tier": "prod"},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, tt.selector.matches(tt.labels)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromBytes_labels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		org      string
+		repo     string
+		expected bool
+	}{
+		{
+			name:     "valid selector",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}], "labels": {"match": {"tier": "prod"}, "expressions": [{"key": "namespace", "operator": "in", "values": ["a"]}]}}}`,
+			repo:     `{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "labels": {"match": {"tier": "prod", "team": "a"}}}]}`,
+			expected: true,
+		},
+		{
+			name:     "invalid operator",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}], "labels": {"expressions": [{"key": "namespace", "operator": "eq"}]}}}`,
+			repo:     `{"version": 1}`,
+			expected: false,
+		},
+		{
+			name:     "in without values",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}], "labels": {"expressions": [{"key": "namespace", "operator": "in"}]}}}`,
+			repo:     `{"version": 1}`,
+			expected: false,
+		},
+		{
+			name:     "exists with values",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}], "labels": {"expressions": [{"key": "namespace", "operator": "exists", "values": ["a"]}]}}}`,
+			repo:     `{"version": 1}`,
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := FromBytes([][]byte{[]byte(tt.org), []byte(tt.repo)})
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_labels(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"sources": [{"uri": "git+https://github.com/org/*"}],
+			"images": [{"uri": "docker://org/*"}],
+			"labels": {"expressions": [{"key": "tier", "operator": "not_in", "values": ["prod"]}]}
+		},
+		"projects": [
+			{
+				"sources": [{"uri": "git+https://github.com/org/*"}],
+				"images": [{"uri": "docker://org/prod-*"}],
+				"labels": {"match": {"tier": "prod"}}
+			}
+		]
+	}`
+	repo := `{
+		"version": 1,
+		"projects": [
+			{
+				"source": {"uri": "git+https://github.com/org/repo"},
+				"labels": {"expressions": [{"key": "namespace", "operator": "in", "values": ["a", "b"]}]}
+			},
+			{
+				"source": {"uri": "git+https://github.com/org/repo"},
+				"labels": {"expressions": [{"key": "namespace", "operator": "not_exists"}]}
+			}
+		]
+	}`
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		imageURI string
+		labels   map[string]string
+		expected func(results.Verification) bool
+	}{
+		{
+			name:     "no labels",
+			imageURI: "docker://org/image",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "dev tier",
+			imageURI: "docker://org/image",
+			labels:   map[string]string{"tier": "dev", "namespace": "a"},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "prod tier selects the project",
+			imageURI: "docker://org/prod-image",
+			labels:   map[string]string{"tier": "prod"},
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "prod tier image mismatch",
+			imageURI: "docker://org/image",
+			labels:   map[string]string{"tier": "prod"},
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "namespace not selected",
+			imageURI: "docker://org/image",
+			labels:   map[string]string{"namespace": "c"},
+			expected: results.Verification.Fail,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate("git+https://github.com/org/repo", tt.imageURI, "builder",
+				options.WithLabels(tt.labels))
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package internal
+
+// syntax is the syntax of the patterns of a policy, given by its version.
+type syntax int
+
+const (
+	// syntaxV1 is the syntax of version 1 policies, matched by Glob:
+	// * matches any characters, including /.
+	syntaxV1 syntax = iota
+	// syntaxV2 is the syntax of version 2 policies, matched by GlobV2.
+	syntaxV2
+)
+
+// syntaxOf returns the pattern syntax of a policy version.
+func syntaxOf(version int) syntax {
+	if version == 2 {
+		return syntaxV2
+	}
+	return syntaxV1
+}
+
+// match tests a pattern against a subject string.
+func (s syntax) match(pattern, subj string) bool {
+	if s == syntaxV2 {
+		return GlobV2(pattern, subj)
+	}
+	return Glob(pattern, subj)
+}
+
+// validate returns an error if the pattern cannot be parsed.
+func (s syntax) validate(pattern string) error {
+	if s != syntaxV2 {
+		return nil
+	}
+	_, err := parseGlobV2(pattern)
+	return err
+}
+
+// any returns a pattern that matches any string.
+func (s syntax) any() string {
+	if s == syntaxV2 {
+		return "**"
+	}
+	return GLOB
+}
+
+// tokens returns the token sequences of the alternatives of a pattern.
+func (s syntax) tokens(pattern string) ([][]token, error) {
+	if s == syntaxV2 {
+		return parseGlobV2(pattern)
+	}
+	tokens := make([]token, 0, len(pattern))
+	for _, c := range pattern {
+		if string(c) == GLOB {
+			tokens = append(tokens, token{kind: tokenDoubleStar})
+			continue
+		}
+		tokens = append(tokens, token{kind: tokenLiteral, char: c})
+	}
+	return [][]token{tokens}, nil
+}
+
+// coverage compares the patterns of a parent policy
+// with the patterns of a child policy.
+type coverage struct {
+	parent, child syntax
+}
+
+// covers returns true if every string matched by the child pattern
+// is also matched by the parent pattern. The comparison is
+// conservative: a child may be reported as not covered even if
+// it is, e.g. if several parent alternatives are needed to cover it.
+func (c coverage) covers(parent, child string) bool {
+	parentAlternatives, err := c.parent.tokens(parent)
+	if err != nil {
+		return false
+	}
+	childAlternatives, err := c.child.tokens(child)
+	if err != nil {
+		return false
+	}
+	for _, childTokens := range childAlternatives {
+		covered := false
+		for _, parentTokens := range parentAlternatives {
+			if coversTokens(parentTokens, childTokens) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// coversTokens returns true if the parent tokens match every string
+// the child tokens match. Each child token is matched as a symbol:
+// a parent * absorbs child tokens that do not match /, and a
+// parent ** absorbs any child token.
+func coversTokens(parent, child []token) bool {
+	// covered[j] is true if the parent tokens processed so far cover child[:j].
+	covered := make([]bool, len(child)+1)
+	covered[0] = true
+	for _, p := range parent {
+		next := make([]bool, len(child)+1)
+		for j := range covered {
+			if !covered[j] {
+				continue
+			}
+			switch p.kind {
+			case tokenStar, tokenDoubleStar:
+				for k := j; k <= len(child); k++ {
+					next[k] = true
+					if k < len(child) && p.kind == tokenStar && !withinSegment(child[k]) {
+						break
+					}
+				}
+			default:
+				if j < len(child) && coversToken(p, child[j]) {
+					next[j+1] = true
+				}
+			}
+		}
+		covered = next
+	}
+	return covered[len(child)]
+}
+
+// withinSegment returns true if the token never matches /.
+func withinSegment(t token) bool {
+	switch t.kind {
+	case tokenLiteral:
+		return t.char != '/'
+	case tokenAny, tokenClass, tokenStar:
+		return true
+	default:
+		return false
+	}
+}
+
+// coversToken returns true if the parent token, which matches a single
+// character, matches every character the child token matches.
+func coversToken(parent, child token) bool {
+	switch child.kind {
+	case tokenLiteral:
+		return parent.matches(child.char)
+	case tokenAny:
+		return parent.kind == tokenAny
+	case tokenClass:
+		switch parent.kind {
+		case tokenAny:
+			return true
+		case tokenClass:
+			return classWithin(child, parent)
+		}
+	}
+	return false
+}
+
+// classWithin returns true if every character of the child class
+// is in the parent class.
+func classWithin(child, parent token) bool {
+	switch {
+	case !child.negated && !parent.negated:
+		for _, r := range child.ranges {
+			if !rangeWithin(r, parent.ranges) {
+				return false
+			}
+		}
+		return true
+	case !child.negated && parent.negated:
+		// The child ranges must be disjoint from the excluded ranges.
+		for _, r := range child.ranges {
+			for _, excluded := range parent.ranges {
+				if r.lo <= excluded.hi && excluded.lo <= r.hi {
+					return false
+				}
+			}
+		}
+		return true
+	case child.negated && parent.negated:
+		// The parent must exclude fewer characters than the child.
+		for _, r := range parent.ranges {
+			if !rangeWithin(r, child.ranges) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// rangeWithin returns true if r is within one of the ranges.
+func rangeWithin(r charRange, ranges []charRange) bool {
+	for _, other := range ranges {
+		if other.lo <= r.lo && r.hi <= other.hi {
+			return true
+		}
+	}
+	return false
+}
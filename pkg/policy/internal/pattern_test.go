@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_coverage_covers(t *testing.T) {
+	t.Parallel()
+
+	v1 := coverage{parent: syntaxV1, child: syntaxV1}
+	v2 := coverage{parent: syntaxV2, child: syntaxV2}
+	tests := []struct {
+		name          string
+		coverage      coverage
+		parent, child string
+		expected      bool
+	}{
+		{name: "v1 glob", coverage: v1, parent: "ghcr.io/org/*", child: "ghcr.io/org/unit-*", expected: true},
+		{name: "v1 literal", coverage: v1, parent: "ghcr.io/org/image", child: "ghcr.io/org/image", expected: true},
+		{name: "v1 wider", coverage: v1, parent: "ghcr.io/org/unit-*", child: "ghcr.io/org/*", expected: false},
+		{name: "v2 star", coverage: v2, parent: "ghcr.io/org/*", child: "ghcr.io/org/unit-*", expected: true},
+		{name: "v2 star and nested child", coverage: v2, parent: "ghcr.io/org/*", child: "ghcr.io/org/unit/*", expected: false},
+		{name: "v2 double star", coverage: v2, parent: "ghcr.io/org/**", child: "ghcr.io/org/unit/*", expected: true},
+		{name: "v2 star and double star child", coverage: v2, parent: "ghcr.io/org/*", child: "ghcr.io/org/**", expected: false},
+		{name: "v2 alternation", coverage: v2, parent: "ghcr.io/{org,other}/*", child: "ghcr.io/org/image", expected: true},
+		{name: "v2 child alternation", coverage: v2, parent: "ghcr.io/org/*", child: "ghcr.io/org/{a,b}", expected: true},
+		{name: "v2 child alternation widens", coverage: v2, parent: "ghcr.io/org/*", child: "ghcr.io/{org,other}/a", expected: false},
+		{name: "v2 class", coverage: v2, parent: "v[0-9]", child: "v[1-3]", expected: true},
+		{name: "v2 class widens", coverage: v2, parent: "v[1-3]", child: "v[0-9]", expected: false},
+		{name: "v2 any", coverage: v2, parent: "v?", child: "v[0-9]", expected: true},
+		{name: "v2 negated class", coverage: v2, parent: "[!a-z]", child: "[0-9]", expected: true},
+		{
+			name:     "v1 parent and v2 child",
+			coverage: coverage{parent: syntaxV1, child: syntaxV2},
+			parent:   "ghcr.io/org/*", child: "ghcr.io/org/**", expected: true,
+		},
+		{
+			name:     "v2 parent and v1 child",
+			coverage: coverage{parent: syntaxV2, child: syntaxV1},
+			parent:   "ghcr.io/org/*", child: "ghcr.io/org/unit-*", expected: false,
+		},
+		{
+			name:     "v2 double star parent and v1 child",
+			coverage: coverage{parent: syntaxV2, child: syntaxV1},
+			parent:   "ghcr.io/org/**", child: "ghcr.io/org/unit-*", expected: true,
+		},
+		{name: "invalid child", coverage: v2, parent: "**", child: "[", expected: false},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, tt.coverage.covers(tt.parent, tt.child)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromBytes_version2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policies []string
+		expected bool
+	}{
+		{
+			name: "version 2",
+			policies: []string{
+				`{"version": 2, "defaults": {"sources": [{"uri": "git+https://github.com/{org,other}/*"}], "images": [{"uri": "ghcr.io/org/**"}]}}`,
+				`{"version": 2, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "image": {"uri": "ghcr.io/org/[a-z]*"}}]}`,
+			},
+			expected: true,
+		},
+		{
+			name:     "version 3",
+			policies: []string{`{"version": 3, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "invalid pattern",
+			policies: []string{`{"version": 2, "defaults": {"sources": [{"uri": "git+https://github.com/org/{a,b"}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "invalid builder pattern",
+			policies: []string{`{"version": 2, "defaults": {"sources": [{"uri": "**"}], "tracks": {"build": {"builders": [{"id": "[z-a]"}]}}}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name: "invalid repo pattern",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`,
+				`{"version": 2, "projects": [{"source": {"uri": "git+https://github.com/org/[repo"}}]}`,
+			},
+			expected: false,
+		},
+		{
+			name: "version 1 patterns are not parsed",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/{a,b"}]}}`,
+				`{"version": 1}`,
+			},
+			expected: true,
+		},
+		{
+			name: "version 2 child of a version 1 parent",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`,
+				`{"version": 2, "projects": [{"source": {"uri": "git+https://github.com/org/**"}}]}`,
+			},
+			expected: true,
+		},
+		{
+			name: "version 1 child widens a version 2 parent",
+			policies: []string{
+				`{"version": 2, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/*"}}]}`,
+			},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := make([][]byte, len(tt.policies))
+			for i := range tt.policies {
+				content[i] = []byte(tt.policies[i])
+			}
+			_, err := FromBytes(content)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_version2(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		version   string
+		sourceURI string
+		imageURI  string
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "version 1 star crosses segments",
+			version:   "1",
+			sourceURI: "git+https://github.com/org/repo/evil",
+			imageURI:  "ghcr.io/org/nested/image",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "version 2 source in a segment",
+			version:   "2",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "ghcr.io/org/image",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "version 2 nested source",
+			version:   "2",
+			sourceURI: "git+https://github.com/org/repo/evil",
+			imageURI:  "ghcr.io/org/image",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "version 2 nested image",
+			version:   "2",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "ghcr.io/org/nested/image",
+			expected:  results.Verification.Fail,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			org := `{"version": ` + tt.version + `, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "images": [{"uri": "ghcr.io/org/*"}]}}`
+			policy, err := FromBytes([][]byte{[]byte(org), []byte(`{"version": 1}`)})
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := policy.Evaluate(tt.sourceURI, tt.imageURI, "builder")
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
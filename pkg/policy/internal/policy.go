@@ -2,36 +2,77 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+	"github.com/laurentsimon/slsa-e2e/pkg/imageref"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
 	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/provenance"
+	"github.com/laurentsimon/slsa-e2e/pkg/sourceuri"
 )
 
 type Builder struct {
-	ID    string `json:"id"`
-	Level int    `json:"level"`
+	ID    Pattern `json:"id"`
+	Level int     `json:"level"`
+
+	// origin is the path of the builder in the policy, set when the
+	// policy is loaded: a project may inherit it from the defaults.
+	origin string
 }
 
+// MergeType defines how a project list is merged with the defaults.
+type MergeType string
+
+const (
+	// MergeTypeReplace replaces the default list. This is the default.
+	MergeTypeReplace = "replace"
+	// MergeTypeExtend appends to the default list.
+	MergeTypeExtend = "extend"
+)
+
 type BuildTrack struct {
 	Builders []Builder `json:"builders"`
+	Merge    MergeType `json:"merge"`
 }
 
 type Sourcer struct {
-	ID string `json:"id"`
+	ID    Pattern `json:"id"`
+	Level int     `json:"level"`
+
+	// origin is the path of the attestor in the policy, set when the
+	// policy is loaded: a project may inherit it from the defaults.
+	origin string
 }
 type SourceTrack struct {
 	Sourcers []Sourcer `json:"attestors"`
+	Merge    MergeType `json:"merge"`
+	// Mode audits the violations of the source track when set to
+	// "audit", e.g. until the builds produce source attestations.
+	Mode Mode `json:"mode"`
 }
 
 type Resource struct {
-	URI string `json:"uri"`
+	URI Pattern `json:"uri"`
 }
 
 type context string
 
+const maxBuildLevel = 4
+
+// digestAlgorithm is the digest algorithm required to pin images.
+const digestAlgorithm = "sha256"
+
+// Policy types, from the root to the leaf of the hierarchy.
 const (
 	contextOrg  context = "org"
+	contextUnit context = "unit"
+	contextTeam context = "team"
 	contextRepo context = "repo"
 )
 
@@ -41,61 +82,308 @@ type Tracks struct {
 }
 
 type Entry struct {
-	Tracks  Tracks     `json:"tracks"`
-	Images  []Resource `json:"images"`
-	Sources []Resource `json:"sources"`
+	Tracks            Tracks         `json:"tracks"`
+	Images            []Resource     `json:"images"`
+	Sources           []Resource     `json:"sources"`
+	Refs              *Refs          `json:"refs"`
+	Labels            *LabelSelector `json:"labels"`
+	RequireBuildLevel int            `json:"require_build_level"`
+}
+
+// EnforcementType defines the action taken on a violation,
+// or whether repos may overwrite it.
+type EnforcementType string
+
+const (
+	EnforcementTypeDeny  = "deny"
+	EnforcementTypeAllow = "allow"
+)
+
+type Source struct {
+	URI Pattern `json:"uri"`
+}
+
+// Exception overwrites the enforcement for a set of sources.
+type Exception struct {
+	Sources     []Source         `json:"sources"`
+	Reason      string           `json:"reason"`
+	Overwrite   *EnforcementType `json:"overwrite"`
+	OnViolation *EnforcementType `json:"onViolation"`
+}
+
+// Overwrite defines whether repos may overwrite the enforcement.
+// A value of "deny" means they may not.
+type Overwrite struct {
+	Default    EnforcementType `json:"default"`
+	Exceptions []Exception     `json:"exceptions"`
 }
 
+// Enforcement defines the action taken on a violation.
+// A value of "deny" fails the verification, "allow" audits it.
+type Enforcement struct {
+	OnViolation EnforcementType `json:"onViolation"`
+	Overwrite   Overwrite       `json:"overwrite"`
+
+	// syntax is the pattern syntax of the exception sources,
+	// set when the policy is loaded.
+	syntax syntax
+}
+
+// Mode defines how violations are handled for the entire org.
+type Mode string
+
+const (
+	// ModeEnforce applies the enforcement. This is the default.
+	ModeEnforce = "enforce"
+	// ModeAudit reports all violations as audit results.
+	ModeAudit = "audit"
+)
+
+// Trust declares the signers of the attestations evaluated
+// against the policy.
+type Trust struct {
+	Roots []TrustRoot `json:"roots"`
+}
+
+// TrustRoot is a signer of provenance. It may only sign
+// the provenance of the builders it declares.
+type TrustRoot struct {
+	dsse.TrustRoot
+	BuilderIDs []Pattern `json:"builder_ids"`
+}
+
+// OrgPolicy is the policy of the org and of the intermediate
+// levels of the hierarchy.
 type OrgPolicy struct {
-	Version  int     `json:"version"`
-	Defaults *Entry  `json:"defaults"`
-	Projects []Entry `json:"projects"`
+	Version     int          `json:"version"`
+	Type        context      `json:"type"`
+	Mode        Mode         `json:"mode"`
+	Defaults    *Entry       `json:"defaults"`
+	Projects    []Entry      `json:"projects"`
+	Enforcement *Enforcement `json:"enforcement"`
+	Trust       *Trust       `json:"trust"`
 }
 
 type Project struct {
-	Source Resource `json:"source"`
-	Image  Resource `json:"image"`
+	Source            Resource       `json:"source"`
+	Image             Resource       `json:"image"`
+	Refs              *Refs          `json:"refs"`
+	Labels            *LabelSelector `json:"labels"`
+	RequireBuildLevel int            `json:"require_build_level"`
+}
+
+// RepoEnforcement defines the action a repo takes on a violation.
+// It is only used if the org allows repos to overwrite it.
+type RepoEnforcement struct {
+	OnViolation EnforcementType `json:"onViolation"`
 }
 
 type RepoPolicy struct {
-	Version  int       `json:"version"`
-	Projects []Project `json:"projects"`
+	Version     int              `json:"version"`
+	Type        context          `json:"type"`
+	Projects    []Project        `json:"projects"`
+	Enforcement *RepoEnforcement `json:"enforcement"`
+}
+
+// level is a policy of the hierarchy that uses the org schema.
+type level struct {
+	context context
+	policy  OrgPolicy
+	// name is the file of the policy, if known.
+	name string
 }
 
 type Policy struct {
-	orgPolicy  OrgPolicy
+	// levels are ordered from the org to the most specific level.
+	levels     []level
 	repoPolicy RepoPolicy
+	repoName   string
+	// verifier verifies attestations with the trust roots of the org, if any.
+	verifier *dsse.Verifier
 }
 
+// ErrNoTrustRoots is returned when verifying an attestation
+// with a policy that declares no trust roots.
+var ErrNoTrustRoots = errors.New("no trust roots")
+
+// FromBytes creates a policy from an ordered list of policies,
+// starting with the org policy. Each policy declares its type.
+// If the type is missing, the first policy is the org
+// and the last one is the repo.
 func FromBytes(content [][]byte) (*Policy, error) {
-	if len(content) > 2 {
-		return nil, fmt.Errorf("invalid level of policies %q", len(content))
+	return fromBytes(make([]string, len(content)), content)
+}
+
+// fromBytes creates a policy from an ordered list of policies
+// and the names of their files.
+func fromBytes(names []string, content [][]byte) (*Policy, error) {
+	if len(content) == 0 {
+		return nil, fmt.Errorf("no policies")
 	}
 
-	pcontent := &content[0]
-	var orgPolicy OrgPolicy
-	if err := json.Unmarshal(*pcontent, &orgPolicy); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	policy := Policy{}
+	for i := range content {
+		pcontent := &content[i]
+		var header struct {
+			Type context `json:"type"`
+		}
+		if err := json.Unmarshal(*pcontent, &header); err != nil {
+			return nil, withFile(names[i], fmt.Errorf("failed to unmarshal: %w", err))
+		}
+		ctx, err := levelType(header.Type, i, len(content))
+		if err != nil {
+			return nil, err
+		}
+		if ctx == contextUnit && len(policy.levels) > 0 && policy.levels[len(policy.levels)-1].context == contextTeam {
+			err = fmt.Errorf("policy %d: %q policy after a %q policy", i, contextUnit, contextTeam)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if ctx == contextRepo {
+			var repoPolicy RepoPolicy
+			if err := json.Unmarshal(*pcontent, &repoPolicy); err != nil {
+				return nil, withFile(names[i], fmt.Errorf("failed to unmarshal: %w", err))
+			}
+			if err := validateRepoPolicy(repoPolicy); err != nil {
+				return nil, withFile(names[i], err)
+			}
+			for i := range repoPolicy.Projects {
+				project := &repoPolicy.Projects[i]
+				project.Source.URI.Glob = sourceuri.NormalizePattern(project.Source.URI.Glob)
+				project.Image.URI.Glob = imageref.NormalizePattern(project.Image.URI.Glob)
+			}
+			if err := validateRepoNarrowing(policy.levels[len(policy.levels)-1], repoPolicy); err != nil {
+				return nil, err
+			}
+			policy.repoPolicy = repoPolicy
+			policy.repoName = names[i]
+			continue
+		}
+
+		var orgPolicy OrgPolicy
+		if err := json.Unmarshal(*pcontent, &orgPolicy); err != nil {
+			return nil, withFile(names[i], fmt.Errorf("failed to unmarshal: %w", err))
+		}
+		if err := validateOrgPolicy(ctx, orgPolicy); err != nil {
+			return nil, withFile(names[i], err)
+		}
+		normalizePatterns(&orgPolicy)
+		recordOrigins(&orgPolicy)
+		for i := range orgPolicy.Projects {
+			orgPolicy.Projects[i] = mergeEntry(*orgPolicy.Defaults, orgPolicy.Projects[i])
+		}
+		if orgPolicy.Enforcement != nil {
+			orgPolicy.Enforcement.syntax = syntaxOf(orgPolicy.Version)
+		}
+		if orgPolicy.Trust != nil {
+			roots := make([]dsse.TrustRoot, len(orgPolicy.Trust.Roots))
+			for i := range orgPolicy.Trust.Roots {
+				roots[i] = orgPolicy.Trust.Roots[i].TrustRoot
+			}
+			verifier, err := dsse.NewVerifier(roots)
+			if err != nil {
+				return nil, fmt.Errorf("%q policy: trust: %w", ctx, err)
+			}
+			policy.verifier = verifier
+		}
+		current := level{context: ctx, policy: orgPolicy, name: names[i]}
+		if len(policy.levels) > 0 {
+			if err := validateNarrowing(policy.levels[len(policy.levels)-1], current); err != nil {
+				return nil, err
+			}
+		}
+		policy.levels = append(policy.levels, current)
+	}
+
+	return &policy, nil
+}
+
+// withFile prefixes the error with the file of the policy, if known.
+func withFile(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// normalizePatterns canonicalizes the source and image patterns
+// of the policy, before the projects are merged with the defaults.
+func normalizePatterns(p *OrgPolicy) {
+	normalizeSources(p.Defaults.Sources)
+	normalizeImages(p.Defaults.Images)
+	for i := range p.Projects {
+		normalizeSources(p.Projects[i].Sources)
+		normalizeImages(p.Projects[i].Images)
+	}
+	if p.Enforcement == nil {
+		return
+	}
+	for i := range p.Enforcement.Overwrite.Exceptions {
+		sources := p.Enforcement.Overwrite.Exceptions[i].Sources
+		for j := range sources {
+			sources[j].URI.Glob = sourceuri.NormalizePattern(sources[j].URI.Glob)
+		}
 	}
-	if err := validateOrgPolicy(orgPolicy); err != nil {
-		return nil, err
+}
+
+// recordOrigins records the path of the builders and source attestors
+// of each entry, before the projects are merged with the defaults.
+func recordOrigins(p *OrgPolicy) {
+	recordEntryOrigins("defaults", p.Defaults)
+	for i := range p.Projects {
+		recordEntryOrigins(fmt.Sprintf("projects[%d]", i), &p.Projects[i])
 	}
+}
 
-	pcontent = &content[1]
-	var repoPolicy RepoPolicy
-	if err := json.Unmarshal(*pcontent, &repoPolicy); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+func recordEntryOrigins(entry string, e *Entry) {
+	for j := range e.Tracks.Build.Builders {
+		e.Tracks.Build.Builders[j].origin = fmt.Sprintf("%s.tracks.build.builders[%d]", entry, j)
 	}
-	if err := validateRepoPolicy(repoPolicy); err != nil {
-		return nil, err
+	for j := range e.Tracks.Source.Sourcers {
+		e.Tracks.Source.Sourcers[j].origin = fmt.Sprintf("%s.tracks.source.attestors[%d]", entry, j)
 	}
+}
 
-	// val, _ := json.MarshalIndent(policies, "", "  ")
-	// fmt.Println(string(val))
-	return &Policy{
-		orgPolicy:  orgPolicy,
-		repoPolicy: repoPolicy,
-	}, nil
+// normalizeImages canonicalizes the image patterns, so that they
+// match the canonical images being evaluated.
+func normalizeImages(images []Resource) {
+	for i := range images {
+		images[i].URI.Glob = imageref.NormalizePattern(images[i].URI.Glob)
+	}
+}
+
+// levelType returns the type of the policy at index i out of n policies.
+func levelType(declared context, i, n int) (context, error) {
+	ctx := declared
+	if ctx == "" {
+		switch {
+		case i == 0:
+			ctx = contextOrg
+		case i == n-1:
+			ctx = contextRepo
+		default:
+			return "", fmt.Errorf("policy %d: empty %q", i, "type")
+		}
+	}
+	switch ctx {
+	case contextOrg:
+		if i != 0 {
+			return "", fmt.Errorf("policy %d: %q policy must be first", i, ctx)
+		}
+	case contextRepo:
+		if i == 0 || i != n-1 {
+			return "", fmt.Errorf("policy %d: %q policy must be last", i, ctx)
+		}
+	case contextUnit, contextTeam:
+		if i == 0 {
+			return "", fmt.Errorf("policy %d: first policy must be of type %q", i, contextOrg)
+		}
+	default:
+		return "", fmt.Errorf("policy %d: invalid %q: %q", i, "type", ctx)
+	}
+	return ctx, nil
 }
 
 func FromFiles(files []string) (*Policy, error) {
@@ -109,140 +397,797 @@ func FromFiles(files []string) (*Policy, error) {
 		contents[i] = content
 	}
 
-	return FromBytes(contents)
+	return fromBytes(files, contents)
 }
 
-func validateOrgPolicy(p OrgPolicy) error {
-	if p.Version != 1 {
-		return fmt.Errorf("%q policy: invalid %q", contextOrg, "source")
+func validateOrgPolicy(ctx context, p OrgPolicy) error {
+	if err := validateVersion(p.Version); err != nil {
+		return fmt.Errorf("%q policy: %w", ctx, err)
+	}
+	syn := syntaxOf(p.Version)
+	if p.Defaults == nil {
+		return fmt.Errorf("%q policy: empty %q", ctx, "defaults")
 	}
 	if len(p.Defaults.Sources) == 0 {
-		return fmt.Errorf("%q policy: empty %q", contextOrg, "sources")
+		return fmt.Errorf("%q policy: empty %q", ctx, "sources")
+	}
+	if p.Defaults.Tracks.Build.Merge != "" || p.Defaults.Tracks.Source.Merge != "" {
+		return fmt.Errorf("%q policy: defaults: unexpected %q", ctx, "merge")
+	}
+	if err := validateEntryLevels(*p.Defaults); err != nil {
+		return fmt.Errorf("%q policy: defaults: %w", ctx, err)
+	}
+	if err := compileEntryPatterns(syn, p.Defaults); err != nil {
+		return fmt.Errorf("%q policy: defaults: %w", ctx, err)
+	}
+	for i := range p.Projects {
+		project := &p.Projects[i]
+		if len(project.Sources) == 0 {
+			return fmt.Errorf("%q policy: project %d: empty %q", ctx, i, "sources")
+		}
+		if err := validateMergeType(project.Tracks.Build.Merge); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", ctx, i, err)
+		}
+		if err := validateMergeType(project.Tracks.Source.Merge); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", ctx, i, err)
+		}
+		if err := validateProjectLists(*project); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", ctx, i, err)
+		}
+		if err := validateEntryLevels(*project); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", ctx, i, err)
+		}
+		if err := compileEntryPatterns(syn, project); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", ctx, i, err)
+		}
+	}
+	if ctx != contextOrg {
+		if p.Mode != "" {
+			return fmt.Errorf("%q policy: unexpected %q", ctx, "mode")
+		}
+		if p.Enforcement != nil {
+			return fmt.Errorf("%q policy: unexpected %q", ctx, "enforcement")
+		}
+		if p.Trust != nil {
+			return fmt.Errorf("%q policy: unexpected %q", ctx, "trust")
+		}
+		return nil
+	}
+	switch p.Mode {
+	case "", ModeEnforce, ModeAudit:
+	default:
+		return fmt.Errorf("%q policy: invalid %q: %q", ctx, "mode", p.Mode)
+	}
+	if p.Trust != nil {
+		if err := validateTrust(syn, p.Trust); err != nil {
+			return fmt.Errorf("%q policy: trust: %w", ctx, err)
+		}
+	}
+	if p.Enforcement != nil {
+		if err := validateEnforcement(*p.Enforcement); err != nil {
+			return fmt.Errorf("%q policy: %w", ctx, err)
+		}
+		for i := range p.Enforcement.Overwrite.Exceptions {
+			sources := p.Enforcement.Overwrite.Exceptions[i].Sources
+			for j := range sources {
+				if err := compilePattern(syn, fmt.Sprintf("sources[%d].uri", j), &sources[j].URI); err != nil {
+					return fmt.Errorf("%q policy: exception %d: %w", ctx, i, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateTrust validates the builders of the trust roots.
+// The roots themselves are validated by the verifier.
+func validateTrust(syn syntax, trust *Trust) error {
+	ids := make(map[string]bool, len(trust.Roots))
+	for i := range trust.Roots {
+		root := &trust.Roots[i]
+		if ids[root.ID] {
+			return fmt.Errorf("duplicate trust root %q", root.ID)
+		}
+		ids[root.ID] = true
+		if len(root.BuilderIDs) == 0 {
+			return fmt.Errorf("trust root %q: empty %q", root.ID, "builder_ids")
+		}
+		for j := range root.BuilderIDs {
+			if err := compilePattern(syn, fmt.Sprintf("builder_ids[%d]", j), &root.BuilderIDs[j]); err != nil {
+				return fmt.Errorf("trust root %q: %w", root.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateVersion validates the version of a policy. Version 2
+// policies use the GlobV2 pattern syntax.
+func validateVersion(version int) error {
+	switch version {
+	case 1, 2:
+		return nil
+	default:
+		return fmt.Errorf("invalid %q: %d", "version", version)
+	}
+}
+
+// compileEntryPatterns validates the patterns of an entry
+// and compiles its predicates.
+func compileEntryPatterns(syn syntax, e *Entry) error {
+	for i := range e.Sources {
+		if err := compilePattern(syn, fmt.Sprintf("sources[%d].uri", i), &e.Sources[i].URI); err != nil {
+			return err
+		}
+	}
+	for i := range e.Images {
+		if err := compilePattern(syn, fmt.Sprintf("images[%d].uri", i), &e.Images[i].URI); err != nil {
+			return err
+		}
+	}
+	for i := range e.Tracks.Build.Builders {
+		if err := compilePattern(syn, fmt.Sprintf("tracks.build.builders[%d].id", i), &e.Tracks.Build.Builders[i].ID); err != nil {
+			return err
+		}
+	}
+	for i := range e.Tracks.Source.Sourcers {
+		if err := compilePattern(syn, fmt.Sprintf("tracks.source.attestors[%d].id", i), &e.Tracks.Source.Sourcers[i].ID); err != nil {
+			return err
+		}
+	}
+	return validateRefsPatterns(syn, e.Refs)
+}
+
+func validateRefsPatterns(syn syntax, refs *Refs) error {
+	if refs == nil {
+		return nil
+	}
+	for i := range refs.Branches {
+		if err := validatePattern(syn, fmt.Sprintf("refs.branches[%d]", i), refs.Branches[i]); err != nil {
+			return err
+		}
+	}
+	for i := range refs.Tags {
+		if err := validatePattern(syn, fmt.Sprintf("refs.tags[%d]", i), refs.Tags[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePattern(syn syntax, field, pattern string) error {
+	if err := syn.validate(pattern); err != nil {
+		return fmt.Errorf("invalid %q: %w", field, err)
+	}
+	return nil
+}
+
+// compilePattern validates a uri or id pattern and compiles its predicate, if any.
+func compilePattern(syn syntax, field string, pattern *Pattern) error {
+	if err := pattern.compile(syn); err != nil {
+		return fmt.Errorf("invalid %q: %w", field, err)
+	}
+	return nil
+}
+
+func validateEnforcement(e Enforcement) error {
+	if err := validateEnforcementType("onViolation", e.OnViolation); err != nil {
+		return err
+	}
+	if err := validateEnforcementType("overwrite.default", e.Overwrite.Default); err != nil {
+		return err
+	}
+	for i := range e.Overwrite.Exceptions {
+		exception := &e.Overwrite.Exceptions[i]
+		if len(exception.Sources) == 0 {
+			return fmt.Errorf("exception %d: empty %q", i, "sources")
+		}
+		if exception.OnViolation != nil {
+			if err := validateEnforcementType("onViolation", *exception.OnViolation); err != nil {
+				return fmt.Errorf("exception %d: %w", i, err)
+			}
+		}
+		if exception.Overwrite != nil {
+			if err := validateEnforcementType("overwrite", *exception.Overwrite); err != nil {
+				return fmt.Errorf("exception %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateEnforcementType(field string, e EnforcementType) error {
+	switch e {
+	case EnforcementTypeDeny, EnforcementTypeAllow:
+		return nil
+	default:
+		return fmt.Errorf("invalid %q: %q", field, e)
+	}
+}
+
+func validateEntryLevels(e Entry) error {
+	switch e.Tracks.Source.Mode {
+	case "", ModeEnforce, ModeAudit:
+	default:
+		return fmt.Errorf("invalid %q: %q", "tracks.source.mode", e.Tracks.Source.Mode)
+	}
+	if err := validateLabelSelector(e.Labels); err != nil {
+		return err
+	}
+	if err := validateRefs(e.Refs); err != nil {
+		return err
+	}
+	if err := validateLevel("require_build_level", e.RequireBuildLevel); err != nil {
+		return err
+	}
+	for i := range e.Tracks.Build.Builders {
+		if err := validateLevel("builders.level", e.Tracks.Build.Builders[i].Level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLevel(field string, level int) error {
+	if level < 0 || level > maxBuildLevel {
+		return fmt.Errorf("invalid %q: %d", field, level)
+	}
+	return nil
+}
+
+func validateMergeType(m MergeType) error {
+	switch m {
+	case "", MergeTypeReplace, MergeTypeExtend:
+		return nil
+	default:
+		return fmt.Errorf("invalid %q: %q", "merge", m)
+	}
+}
+
+// validateProjectLists rejects explicit empty lists in a project.
+// A project list replaces the default one, and an empty list
+// would allow any image, builder or source attestor. Projects
+// omit the field to inherit the defaults.
+func validateProjectLists(project Entry) error {
+	switch {
+	case project.Images != nil && len(project.Images) == 0:
+		return fmt.Errorf("empty %q", "images")
+	case project.Tracks.Build.Builders != nil && len(project.Tracks.Build.Builders) == 0:
+		return fmt.Errorf("empty %q", "tracks.build.builders")
+	case project.Tracks.Source.Sourcers != nil && len(project.Tracks.Source.Sourcers) == 0:
+		return fmt.Errorf("empty %q", "tracks.source.attestors")
 	}
 	return nil
 }
 
+// mergeEntry returns the project entry with the fields it does not
+// set inherited from the defaults. Builders and attestors
+// either replace or extend the default lists.
+func mergeEntry(defaults, project Entry) Entry {
+	merged := project
+	if merged.Images == nil {
+		merged.Images = defaults.Images
+	}
+	if merged.Refs == nil {
+		merged.Refs = defaults.Refs
+	}
+	if merged.Labels == nil {
+		merged.Labels = defaults.Labels
+	}
+	if merged.RequireBuildLevel == 0 {
+		merged.RequireBuildLevel = defaults.RequireBuildLevel
+	}
+	if merged.Tracks.Source.Mode == "" {
+		merged.Tracks.Source.Mode = defaults.Tracks.Source.Mode
+	}
+	merged.Tracks.Build.Builders = mergeList(defaults.Tracks.Build.Builders,
+		project.Tracks.Build.Builders, project.Tracks.Build.Merge,
+		func(b Builder) string { return b.ID.String() })
+	merged.Tracks.Source.Sourcers = mergeList(defaults.Tracks.Source.Sourcers,
+		project.Tracks.Source.Sourcers, project.Tracks.Source.Merge,
+		func(s Sourcer) string { return s.ID.String() })
+	return merged
+}
+
+func mergeList[K any](defaults, project []K, merge MergeType, id func(K) string) []K {
+	if project == nil {
+		return defaults
+	}
+	if merge != MergeTypeExtend {
+		return project
+	}
+	merged := make([]K, 0, len(defaults)+len(project))
+	seen := make(map[string]bool, len(defaults))
+	for i := range defaults {
+		merged = append(merged, defaults[i])
+		seen[id(defaults[i])] = true
+	}
+	for i := range project {
+		if seen[id(project[i])] {
+			continue
+		}
+		merged = append(merged, project[i])
+	}
+	return merged
+}
+
 func validateRepoPolicy(p RepoPolicy) error {
-	if p.Version != 1 {
-		return fmt.Errorf("%q policy: invalid %q", contextRepo, "source")
+	if err := validateVersion(p.Version); err != nil {
+		return fmt.Errorf("%q policy: %w", contextRepo, err)
 	}
+	syn := syntaxOf(p.Version)
 	for i := range p.Projects {
 		project := &p.Projects[i]
-		if project.Source.URI == "" {
+		if project.Source.URI.empty() {
 			return fmt.Errorf("%q policy: empty %q", contextRepo, "source")
 		}
+		if err := compilePattern(syn, "source.uri", &project.Source.URI); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", contextRepo, i, err)
+		}
+		if err := compilePattern(syn, "image.uri", &project.Image.URI); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", contextRepo, i, err)
+		}
+		if err := validateRefsPatterns(syn, project.Refs); err != nil {
+			return fmt.Errorf("%q policy: project %d: %w", contextRepo, i, err)
+		}
+		if err := validateLevel("require_build_level", project.RequireBuildLevel); err != nil {
+			return fmt.Errorf("%q policy: %w", contextRepo, err)
+		}
+		if err := validateLabelSelector(project.Labels); err != nil {
+			return fmt.Errorf("%q policy: %w", contextRepo, err)
+		}
+		if err := validateRefs(project.Refs); err != nil {
+			return fmt.Errorf("%q policy: %w", contextRepo, err)
+		}
+	}
+	if p.Enforcement != nil {
+		if err := validateEnforcementType("onViolation", p.Enforcement.OnViolation); err != nil {
+			return fmt.Errorf("%q policy: %w", contextRepo, err)
+		}
 	}
 	return nil
 }
 
-func (p *Policy) Evaluate(sourceURI, imageURI, builderID string) results.Verification {
-	// Try the default policy first.
-	orgDefault := p.verifyOrgDefault(sourceURI, imageURI, builderID)
-	if orgDefault.Pass() {
-		return orgDefault
+// VerifyAttestation verifies a DSSE envelope or a Sigstore bundle of
+// a provenance with the trust roots of the org and returns its payload.
+// The builder of the provenance must be one the trust root that signed
+// it declares. It returns ErrNoTrustRoots if the org declares no trust roots.
+func (p *Policy) VerifyAttestation(content []byte) ([]byte, error) {
+	if p.verifier == nil {
+		return nil, ErrNoTrustRoots
+	}
+	payload, rootID, err := p.verifier.Verify(content)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", contextOrg, err)
+	}
+	prov, err := provenance.FromBytes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%q: failed to read provenance: %w", contextOrg, err)
 	}
-	return p.verifyOrgProjects(sourceURI, imageURI, builderID)
+	if !p.trustRootSigns(rootID, prov.BuilderID) {
+		return nil, fmt.Errorf("%q: trust root %q: builder %q not allowed", contextOrg, rootID, prov.BuilderID)
+	}
+	return payload, nil
 }
 
-func (p *Policy) verifyOrgProjects(sourceURI, imageURI, builderID string) results.Verification {
-	if len(p.orgPolicy.Projects) == 0 {
-		return results.VerificationPass()
-	}
-	// TODO: need to use the defaults and update fields
-	// that are specified.
-	for i := range p.orgPolicy.Projects {
-		project := &p.orgPolicy.Projects[i]
-		result := p.verifyOrgEntry(*project, sourceURI, imageURI, builderID)
-		if result.Pass() {
-			return result
+// trustRootSigns returns true if the trust root may sign
+// the provenance of the builder.
+func (p *Policy) trustRootSigns(rootID, builderID string) bool {
+	org := p.org()
+	syn := syntaxOf(org.Version)
+	in := &input{builder: builderID}
+	for i := range org.Trust.Roots {
+		root := &org.Trust.Roots[i]
+		if root.ID != rootID {
+			continue
+		}
+		for j := range root.BuilderIDs {
+			if root.BuilderIDs[j].match(syn.match, builderID, in) {
+				return true
+			}
 		}
 	}
-	return results.VerificationFail(fmt.Errorf("policy failure"))
+	return false
 }
-func (p *Policy) verifyOrgDefault(sourceURI, imageURI, builderID string) results.Verification {
-	return p.verifyOrgEntry(*p.orgPolicy.Defaults, sourceURI, imageURI, builderID)
+
+// org returns the org policy, which is the root of the hierarchy.
+func (p *Policy) org() *OrgPolicy {
+	return &p.levels[0].policy
 }
 
-func (p *Policy) verifyOrgEntry(entry Entry, sourceURI, imageURI, builderID string) results.Verification {
-	// Sources are validated and are non-empty.
-	for i := range entry.Sources {
-		orgSource := &entry.Sources[i]
-		orgURI := orgSource.URI
-		if !Glob(orgURI, sourceURI) {
-			continue
+// Evaluate evaluates the policy. The result lists the rules checked
+// and the entries satisfied at each level of the hierarchy.
+func (p *Policy) Evaluate(sourceURI, imageURI, builderID string, opts ...options.Option) results.Verification {
+	evaluation := options.New(opts...)
+	// Sources are matched in their canonical form, as are the patterns.
+	source, err := sourceuri.Parse(sourceURI)
+	if err != nil {
+		return results.VerificationInvalid(err)
+	}
+	sourceURI = source.String()
+	image, err := imageref.Parse(imageURI)
+	if err != nil {
+		return results.VerificationInvalid(err)
+	}
+	if evaluation.Subjects != nil {
+		if err := verifySubjects(image, evaluation.Subjects); err != nil {
+			return results.VerificationInvalid(err)
 		}
+		// Images are matched by name: the digest is tied to the attestation.
+		image = image.WithoutDigest()
+	}
+	// Images are matched in their canonical form, as are the patterns.
+	imageURI = image.String()
 
-		// We have a match on the source.
+	in := newInput(sourceURI, imageURI, builderID, evaluation)
+	var rec recorder
+	violations, builderLevel := p.evaluate(&rec, sourceURI, imageURI, builderID, evaluation, in)
+	var result results.Verification
+	switch {
+	case len(violations) == 0:
+		result = results.VerificationPass()
+	case audited(violations) || p.org().Mode == ModeAudit || p.onViolation(in) == EnforcementTypeAllow:
+		result = results.VerificationAudit("policy violations", violations...)
+	default:
+		result = results.VerificationFail(errors.Join(violations...))
+	}
+	return result.WithRules(rec.rules, rec.matches).WithBuildLevel(builderLevel)
+}
 
-		// 1. Verify the org images.
-		ok := verifyEntryResource(entry.Images, imageURI)
-		if !ok {
-			return results.VerificationFail(fmt.Errorf("%q: image uri mismatch: %q", contextOrg, imageURI))
-		}
+// verifySubjects verifies that the image is pinned by a sha256 digest
+// that appears in the subjects.
+func verifySubjects(image imageref.Reference, subjects []intoto.ResourceDescriptor) error {
+	if image.Digest == "" {
+		return fmt.Errorf("image %q: no digest", image)
+	}
+	alg, value, _ := strings.Cut(image.Digest, ":")
+	if alg != digestAlgorithm {
+		return fmt.Errorf("image %q: digest must be %s", image, digestAlgorithm)
+	}
+	if !intoto.HasDigest(subjects, digestAlgorithm, value) {
+		return fmt.Errorf("image %q: digest not in the attestation subjects", image)
+	}
+	return nil
+}
 
-		// 2. verify org build track.
-		ok = verifyBuildTrack(entry.Tracks.Build.Builders, builderID)
-		if !ok {
-			return results.VerificationFail(fmt.Errorf("%q: builder ID mismatch: %q", contextOrg, builderID))
+// evaluate returns the violations of the policy and the level of the builder.
+// Each level of the hierarchy must be satisfied by one of its entries.
+// Predicates are evaluated against in.
+func (p *Policy) evaluate(rec *recorder, sourceURI, imageURI, builderID string, evaluation options.Evaluation, in *input) ([]error, int) {
+	var violations []error
+	// The builder level is the lowest level given by the levels
+	// that restrict builders.
+	builderLevel := -1
+	for i := range p.levels {
+		lvl := &p.levels[i]
+		level, levelViolations := verifyLevel(rec, *lvl, i, sourceURI, imageURI, builderID, evaluation, in)
+		violations = append(violations, levelViolations...)
+		if level >= 0 && (builderLevel < 0 || level < builderLevel) {
+			builderLevel = level
 		}
+	}
+	if builderLevel < 0 {
+		builderLevel = 0
+	}
 
-		// Verify the repo policy.
-		ok, err := verifyRepoProjects(p.repoPolicy, sourceURI, imageURI)
-		if err != nil {
-			return results.VerificationInvalid(err)
+	// Verify the repo policy.
+	repo := scope{policy: p.repoName, level: len(p.levels), context: contextRepo, syntax: syntaxOf(p.repoPolicy.Version)}
+	if err := verifyRepoProjects(rec, repo, p.repoPolicy, sourceURI, imageURI, builderID, builderLevel, evaluation.Labels, in); err != nil {
+		violations = append(violations, fmt.Errorf("%q: %w", contextRepo, err))
+	}
+	return violations, builderLevel
+}
+
+// verifyLevel returns the violations of a level, or nil if one of
+// its entries is satisfied. It also returns the level of the builder
+// of the satisfied entry, or -1 if the entry does not restrict builders.
+func verifyLevel(rec *recorder, lvl level, index int, sourceURI, imageURI, builderID string, evaluation options.Evaluation, in *input) (int, []error) {
+	// Try the default policy first.
+	// NOTE: projects were merged with the defaults when the policy was loaded.
+	levelEntries := entries(lvl.policy)
+	var violations, audits []error
+	var auditedScope scope
+	auditedLevel := -1
+	for i := range levelEntries {
+		s := entryScope(lvl, index, i)
+		matched, builderLevel, entryViolations := verifyOrgEntry(rec, s, levelEntries[i], sourceURI, imageURI, builderID, evaluation, in)
+		if !matched {
+			continue
+		}
+		var errs []error
+		for _, err := range entryViolations {
+			errs = append(errs, fmt.Errorf("%q: %s: %w", lvl.context, entryName(i), err))
+		}
+		if len(errs) == 0 {
+			rec.match(s)
+			return builderLevel, nil
 		}
-		if ok {
-			return results.VerificationPass()
+		// An entry with audited violations only is satisfied,
+		// unless another entry is satisfied without violations.
+		if audited(entryViolations) {
+			if audits == nil {
+				audits, auditedScope, auditedLevel = errs, s, builderLevel
+			}
+			continue
+		}
+		violations = append(violations, errs...)
+	}
+	if audits != nil {
+		rec.match(auditedScope)
+		return auditedLevel, audits
+	}
+	if len(violations) == 0 {
+		if len(evaluation.Labels) > 0 {
+			return -1, []error{fmt.Errorf("%q: no entry for source %q and labels %q", lvl.context, sourceURI, formatLabels(evaluation.Labels))}
 		}
+		return -1, []error{fmt.Errorf("%q: source uri mismatch: %q", lvl.context, sourceURI)}
+	}
+	return -1, violations
+}
+
+func entryName(i int) string {
+	if i == 0 {
+		return "defaults"
+	}
+	return fmt.Sprintf("project %d", i-1)
+}
+
+// onViolation returns the action to take when the policy is violated for the input.
+// The repo policy decides only if the org allows it to overwrite the enforcement.
+func (p *Policy) onViolation(in *input) EnforcementType {
+	org := p.org()
+	if org.Enforcement == nil {
+		return EnforcementTypeDeny
+	}
+	onViolation, overwrite := effectiveEnforcement(*org.Enforcement, in)
+	if overwrite == EnforcementTypeAllow && p.repoPolicy.Enforcement != nil {
+		return p.repoPolicy.Enforcement.OnViolation
+	}
+	return onViolation
+}
+
+// enforced returns true if violations for sourceURI are denied
+// and repos may not overwrite it.
+func enforced(enforcement Enforcement, sourceURI string) bool {
+	onViolation, overwrite := effectiveEnforcement(enforcement, &input{source: sourceURI})
+	return onViolation == EnforcementTypeDeny && overwrite == EnforcementTypeDeny
+}
+
+// effectiveEnforcement returns the action on violation and the overwrite
+// for the input, after applying the first exception matching its source.
+func effectiveEnforcement(enforcement Enforcement, in *input) (EnforcementType, EnforcementType) {
+	onViolation := enforcement.OnViolation
+	overwrite := enforcement.Overwrite.Default
+	exception := findException(enforcement.syntax, enforcement.Overwrite.Exceptions, in)
+	if exception == nil {
+		return onViolation, overwrite
+	}
+	if exception.OnViolation != nil {
+		onViolation = *exception.OnViolation
+	}
+	if exception.Overwrite != nil {
+		overwrite = *exception.Overwrite
+	}
+	return onViolation, overwrite
+}
+
+func findException(syn syntax, exceptions []Exception, in *input) *Exception {
+	for i := range exceptions {
+		exception := &exceptions[i]
+		for j := range exception.Sources {
+			if matchSource(syn, exception.Sources[j].URI, in) {
+				return exception
+			}
+		}
+	}
+	return nil
+}
+
+// verifyOrgEntry returns whether the entry applies to sourceURI
+// and, if so, the level of the matching builder and the list of
+// its violations. The level is -1 if the entry does not restrict builders.
+// NOTE: projects are merged with the defaults, so the rules of a project
+// include those it inherits.
+func verifyOrgEntry(rec *recorder, s scope, entry Entry, sourceURI, imageURI, builderID string, evaluation options.Evaluation, in *input) (bool, int, []error) {
+	// Sources are validated and are non-empty.
+	if !verifyEntrySource(rec, s, entry.Sources, in) {
+		return false, -1, nil
+	}
+	// The entry only applies to the labels it selects.
+	if !verifyLabels(rec, s, entry.Labels, evaluation.Labels) {
+		return false, -1, nil
+	}
+
+	// We have a match on the source.
+	var violations []error
 
+	// 1. Verify the images.
+	if !verifyEntryResource(rec, s, "images", entry.Images, imageURI, in) {
+		violations = append(violations, fmt.Errorf("image uri mismatch: %q", imageURI))
 	}
 
-	return results.VerificationFail(fmt.Errorf("policy failure"))
+	// 2. Verify the source ref.
+	if err := verifyRefs(rec, s, entry.Refs, sourceURI); err != nil {
+		violations = append(violations, err)
+	}
+
+	// 3. verify build track.
+	builderLevel, ok := verifyBuildTrack(rec, s, entry.Tracks.Build.Builders, builderID, in)
+	if !ok {
+		violations = append(violations, fmt.Errorf("builder ID mismatch: %q", builderID))
+	} else if err := verifyBuildLevel(rec, s, builderID, builderLevel, entry.RequireBuildLevel, "entry"); err != nil {
+		violations = append(violations, err)
+	}
+	if len(entry.Tracks.Build.Builders) == 0 {
+		builderLevel = -1
+	}
+
+	// 4. verify source track.
+	if err := verifySourceTrack(rec, s, entry.Tracks.Source.Sourcers, evaluation, in); err != nil {
+		if entry.Tracks.Source.Mode == ModeAudit {
+			err = auditedError{err: err}
+		}
+		violations = append(violations, err)
+	}
+
+	return true, builderLevel, violations
+}
+
+// auditedError is a violation reported as an audit result
+// instead of failing the evaluation.
+type auditedError struct {
+	err error
+}
+
+func (e auditedError) Error() string {
+	return fmt.Sprintf("audited: %v", e.err)
 }
 
-func verifyRepoProjects(repoPolicy RepoPolicy, sourceURI, imageURI string) (bool, error) {
+func (e auditedError) Unwrap() error {
+	return e.err
+}
+
+// audited returns true if all the violations are audited.
+func audited(violations []error) bool {
+	for _, err := range violations {
+		var auditErr auditedError
+		if !errors.As(err, &auditErr) {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyRepoProjects(rec *recorder, repo scope, repoPolicy RepoPolicy, sourceURI, imageURI, builderID string, builderLevel int, labels map[string]string, in *input) error {
 	if len(repoPolicy.Projects) == 0 {
-		return true, nil
+		return nil
 	}
+	var levelErr error
 	for i := range repoPolicy.Projects {
 		repoProject := &repoPolicy.Projects[i]
-		ok := verifyRepoEntry(*repoProject, sourceURI, imageURI)
-		if ok {
-			return true, nil
+		s := repo
+		s.entry = fmt.Sprintf("projects[%d]", i)
+		if !verifyRepoEntry(rec, s, *repoProject, in) ||
+			!verifyLabels(rec, s, repoProject.Labels, labels) {
+			continue
+		}
+		err := verifyRefs(rec, s, repoProject.Refs, sourceURI)
+		if err == nil {
+			err = verifyBuildLevel(rec, s, builderID, builderLevel, repoProject.RequireBuildLevel, "project")
 		}
+		if err == nil {
+			rec.match(s)
+			return nil
+		}
+		levelErr = err
+	}
+	if levelErr != nil {
+		return levelErr
 	}
-	return false, nil
+	if len(labels) > 0 {
+		return fmt.Errorf("no project for source %q, image %q and labels %q", sourceURI, imageURI, formatLabels(labels))
+	}
+	return fmt.Errorf("no project for source %q and image %q", sourceURI, imageURI)
 }
 
-func verifyRepoEntry(project Project, sourceURI, imageURI string) bool {
-	sourceMatch := project.Source.URI == "" || Glob(project.Source.URI, sourceURI)
-	imageMatch := project.Image.URI == "" || Glob(project.Image.URI, imageURI)
-	return sourceMatch && imageMatch
+// verifyLabels returns true if the labels match the selector.
+func verifyLabels(rec *recorder, s scope, selector *LabelSelector, labels map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+	return rec.rule(s, "labels", selector.String(), formatLabels(labels), selector.matches(labels))
 }
 
-func verifyBuildTrack(builders []Builder, builderID string) bool {
+func verifyRepoEntry(rec *recorder, s scope, project Project, in *input) bool {
+	sourceMatch := project.Source.URI.empty() ||
+		rec.rule(s, "source.uri", project.Source.URI.String(), in.source, matchSource(s.syntax, project.Source.URI, in))
+	if !sourceMatch {
+		return false
+	}
+	imageMatch := project.Image.URI.empty() ||
+		rec.rule(s, "image.uri", project.Image.URI.String(), in.image, project.Image.URI.match(s.syntax.match, in.image, in))
+	return imageMatch
+}
+
+// verifyBuildTrack returns the level of the builder matching builderID.
+// If several builders match, the highest level is returned.
+func verifyBuildTrack(rec *recorder, s scope, builders []Builder, builderID string, in *input) (int, bool) {
 	if len(builders) == 0 {
-		return true
+		return 0, true
 	}
+	level, ok := 0, false
 	for j := range builders {
 		b := &builders[j]
 		bid := b.ID
-		if Glob(bid, builderID) {
+		path := itemPath(s, b.origin, fmt.Sprintf("tracks.build.builders[%d]", j)) + ".id"
+		if rec.ruleAt(s, path, bid.String(), builderID, bid.match(s.syntax.match, builderID, in)) {
+			ok = true
+			if b.Level > level {
+				level = b.Level
+			}
+		}
+	}
+
+	return level, ok
+}
+
+func verifyBuildLevel(rec *recorder, s scope, builderID string, level, required int, requirer string) error {
+	if required > 0 && !rec.rule(s, "require_build_level", strconv.Itoa(required), strconv.Itoa(level), level >= required) {
+		return fmt.Errorf("builder %q is level %d, %s requires %d", builderID, level, requirer, required)
+	}
+	return nil
+}
+
+func verifySourceTrack(rec *recorder, s scope, sourcers []Sourcer, evaluation options.Evaluation, in *input) error {
+	if len(sourcers) == 0 {
+		return nil
+	}
+	attestorID := evaluation.SourceAttestorID
+	if attestorID == "" {
+		for j := range sourcers {
+			path := itemPath(s, sourcers[j].origin, fmt.Sprintf("tracks.source.attestors[%d]", j)) + ".id"
+			rec.ruleAt(s, path, sourcers[j].ID.String(), "", false)
+		}
+		return fmt.Errorf("no source attestation")
+	}
+	for j := range sourcers {
+		sourcer := &sourcers[j]
+		path := itemPath(s, sourcer.origin, fmt.Sprintf("tracks.source.attestors[%d]", j))
+		if !rec.ruleAt(s, path+".id", sourcer.ID.String(), attestorID, sourcer.ID.match(s.syntax.match, attestorID, in)) {
+			continue
+		}
+		// An attestor cannot vouch for a level above its own.
+		if evaluation.SourceLevel != nil && sourcer.Level > 0 &&
+			!rec.ruleAt(s, path+".level", strconv.Itoa(sourcer.Level), strconv.Itoa(*evaluation.SourceLevel), *evaluation.SourceLevel <= sourcer.Level) {
+			return fmt.Errorf("source attestor %q is level %d, attested level is %d",
+				attestorID, sourcer.Level, *evaluation.SourceLevel)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("source attestor mismatch: %q", attestorID)
+}
+
+func verifyEntrySource(rec *recorder, s scope, sources []Resource, in *input) bool {
+	for j := range sources {
+		path := fmt.Sprintf("sources[%d].uri", j)
+		if rec.rule(s, path, sources[j].URI.String(), in.source, matchSource(s.syntax, sources[j].URI, in)) {
 			return true
 		}
 	}
 
 	return false
-
 }
-func verifyEntryResource(resources []Resource, resourceURI string) bool {
+
+func verifyEntryResource(rec *recorder, s scope, field string, resources []Resource, resourceURI string, in *input) bool {
 	if len(resources) == 0 {
 		return true
 	}
 	for j := range resources {
 		r := &resources[j]
 		rURI := r.URI
-		if Glob(rURI, resourceURI) {
+		path := fmt.Sprintf("%s[%d].uri", field, j)
+		if rec.rule(s, path, rURI.String(), resourceURI, rURI.match(s.syntax.match, resourceURI, in)) {
 			return true
 		}
 	}
@@ -1,11 +1,23 @@
 package internal
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
 	"github.com/laurentsimon/slsa-e2e/pkg/policy/internal/utils/pointer"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
 )
 
 func Test_enforced(t *testing.T) {
@@ -29,7 +41,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -51,7 +63,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -72,7 +84,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -93,7 +105,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -114,7 +126,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -135,7 +147,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -157,7 +169,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -179,7 +191,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -201,7 +213,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -223,7 +235,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -245,7 +257,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -266,7 +278,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -287,7 +299,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -309,7 +321,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite: pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -330,7 +342,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -352,7 +364,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							Overwrite:   pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -374,7 +386,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 						},
@@ -394,7 +406,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							OnViolation: pointer.To(EnforcementType(EnforcementTypeDeny)),
@@ -415,7 +427,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 						},
@@ -435,7 +447,7 @@ func Test_enforced(t *testing.T) {
 						{
 							Sources: []Source{
 								{
-									URI: "git+https://github.com/org/repo",
+									URI: Pattern{Glob: "git+https://github.com/org/repo"},
 								},
 							},
 							OnViolation: pointer.To(EnforcementType(EnforcementTypeAllow)),
@@ -457,3 +469,1057 @@ func Test_enforced(t *testing.T) {
 		})
 	}
 }
+
+func Test_mergeEntry(t *testing.T) {
+	t.Parallel()
+
+	defaults := Entry{
+		Tracks: Tracks{
+			Build: BuildTrack{
+				Builders: []Builder{{ID: Pattern{Glob: "builder1"}, Level: 3}},
+			},
+			Source: SourceTrack{
+				Sourcers: []Sourcer{{ID: Pattern{Glob: "attestor1"}}},
+			},
+		},
+		Images:  []Resource{{URI: Pattern{Glob: "docker://org/*"}}},
+		Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/*"}}},
+	}
+
+	tests := []struct {
+		name     string
+		project  Entry
+		expected Entry
+	}{
+		{
+			name: "sources only",
+			project: Entry{
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+			expected: Entry{
+				Tracks:  defaults.Tracks,
+				Images:  defaults.Images,
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+		},
+		{
+			name: "replace builders",
+			project: Entry{
+				Tracks: Tracks{
+					Build: BuildTrack{
+						Builders: []Builder{{ID: Pattern{Glob: "builder2"}, Level: 2}},
+					},
+				},
+				Images:  []Resource{{URI: Pattern{Glob: "docker://org/image"}}},
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+			expected: Entry{
+				Tracks: Tracks{
+					Build: BuildTrack{
+						Builders: []Builder{{ID: Pattern{Glob: "builder2"}, Level: 2}},
+					},
+					Source: defaults.Tracks.Source,
+				},
+				Images:  []Resource{{URI: Pattern{Glob: "docker://org/image"}}},
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+		},
+		{
+			name: "extend builders and attestors",
+			project: Entry{
+				Tracks: Tracks{
+					Build: BuildTrack{
+						Builders: []Builder{{ID: Pattern{Glob: "builder1"}, Level: 3}, {ID: Pattern{Glob: "builder2"}, Level: 2}},
+						Merge:    MergeTypeExtend,
+					},
+					Source: SourceTrack{
+						Sourcers: []Sourcer{{ID: Pattern{Glob: "attestor2"}}},
+						Merge:    MergeTypeExtend,
+					},
+				},
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+			expected: Entry{
+				Tracks: Tracks{
+					Build: BuildTrack{
+						Builders: []Builder{{ID: Pattern{Glob: "builder1"}, Level: 3}, {ID: Pattern{Glob: "builder2"}, Level: 2}},
+						Merge:    MergeTypeExtend,
+					},
+					Source: SourceTrack{
+						Sourcers: []Sourcer{{ID: Pattern{Glob: "attestor1"}}, {ID: Pattern{Glob: "attestor2"}}},
+						Merge:    MergeTypeExtend,
+					},
+				},
+				Images:  defaults.Images,
+				Sources: []Resource{{URI: Pattern{Glob: "git+https://github.com/org/repo"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := mergeEntry(defaults, tt.project)
+			if diff := cmp.Diff(tt.expected, result, cmpopts.IgnoreUnexported(Pattern{}, Builder{}, Sourcer{})); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromBytes_emptyProjectLists(t *testing.T) {
+	t.Parallel()
+
+	org := func(project string) string {
+		return `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}],
+			"images": [{"uri": "docker.io/org/*"}],
+			"tracks": {"build": {"builders": [{"id": "builder1", "level": 3}]}, "source": {"attestors": [{"id": "attestor1"}]}}},
+			"projects": [` + project + `]}`
+	}
+	tests := []struct {
+		name     string
+		project  string
+		expected bool
+	}{
+		{
+			name:     "inherited lists",
+			project:  `{"sources": [{"uri": "git+https://github.com/org/repo"}]}`,
+			expected: true,
+		},
+		{
+			name:     "empty images",
+			project:  `{"sources": [{"uri": "git+https://github.com/org/repo"}], "images": []}`,
+			expected: false,
+		},
+		{
+			name:     "empty builders",
+			project:  `{"sources": [{"uri": "git+https://github.com/org/repo"}], "tracks": {"build": {"builders": []}}}`,
+			expected: false,
+		},
+		{
+			name:     "empty extended builders",
+			project:  `{"sources": [{"uri": "git+https://github.com/org/repo"}], "tracks": {"build": {"builders": [], "merge": "extend"}}}`,
+			expected: false,
+		},
+		{
+			name:     "empty attestors",
+			project:  `{"sources": [{"uri": "git+https://github.com/org/repo"}], "tracks": {"source": {"attestors": []}}}`,
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := FromBytes([][]byte{[]byte(org(tt.project)), []byte(`{"version": 1}`)})
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_verifySourceTrack(t *testing.T) {
+	t.Parallel()
+
+	sourcers := []Sourcer{
+		{ID: Pattern{Glob: "https://github.com/source-attestor"}, Level: 3},
+		{ID: Pattern{Glob: "https://cloudbuild.googleapis.com/*"}},
+	}
+
+	tests := []struct {
+		name     string
+		sourcers []Sourcer
+		opts     []options.Option
+		expected bool
+	}{
+		{
+			name:     "no attestors",
+			expected: true,
+		},
+		{
+			name:     "no attestation",
+			sourcers: sourcers,
+			expected: false,
+		},
+		{
+			name:     "attestor match",
+			sourcers: sourcers,
+			opts:     []options.Option{options.WithSourceAttestation("https://github.com/source-attestor")},
+			expected: true,
+		},
+		{
+			name:     "attestor glob match",
+			sourcers: sourcers,
+			opts:     []options.Option{options.WithSourceAttestation("https://cloudbuild.googleapis.com/GoogleSourceAttestor")},
+			expected: true,
+		},
+		{
+			name:     "attestor mismatch",
+			sourcers: sourcers,
+			opts:     []options.Option{options.WithSourceAttestation("https://github.com/other-attestor")},
+			expected: false,
+		},
+		{
+			name:     "level within attestor level",
+			sourcers: sourcers,
+			opts: []options.Option{
+				options.WithSourceAttestation("https://github.com/source-attestor"),
+				options.WithSourceLevel(3),
+			},
+			expected: true,
+		},
+		{
+			name:     "level above attestor level",
+			sourcers: sourcers,
+			opts: []options.Option{
+				options.WithSourceAttestation("https://github.com/source-attestor"),
+				options.WithSourceLevel(4),
+			},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			evaluation := options.New(tt.opts...)
+			err := verifySourceTrack(nil, scope{}, tt.sourcers, evaluation, newInput("", "", "", evaluation))
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_verifyBuildLevel(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"tracks": {
+				"build": {
+					"builders": [
+						{"id": "https://github.com/builder-l2", "level": 2},
+						{"id": "https://github.com/builder-l3", "level": 3}
+					]
+				}
+			},
+			"sources": [{"uri": "git+https://github.com/org/*"}],
+			"require_build_level": 2
+		},
+		"projects": [
+			{
+				"sources": [{"uri": "git+https://github.com/critical/*"}],
+				"require_build_level": 3
+			}
+		]
+	}`
+	repo := `{
+		"version": 1,
+		"projects": [
+			{
+				"source": {"uri": "git+https://github.com/org/repo"},
+				"require_build_level": 3
+			},
+			{
+				"source": {"uri": "git+https://github.com/critical/repo"}
+			},
+			{
+				"source": {"uri": "git+https://github.com/org/other"}
+			}
+		]
+	}`
+
+	tests := []struct {
+		name      string
+		sourceURI string
+		builderID string
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "org level met",
+			sourceURI: "git+https://github.com/org/other",
+			builderID: "https://github.com/builder-l2",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "repo level not met",
+			sourceURI: "git+https://github.com/org/repo",
+			builderID: "https://github.com/builder-l2",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "repo level met",
+			sourceURI: "git+https://github.com/org/repo",
+			builderID: "https://github.com/builder-l3",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "project level not met",
+			sourceURI: "git+https://github.com/critical/repo",
+			builderID: "https://github.com/builder-l2",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "project level met",
+			sourceURI: "git+https://github.com/critical/repo",
+			builderID: "https://github.com/builder-l3",
+			expected:  results.Verification.Pass,
+		},
+	}
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate(tt.sourceURI, "docker://org/image", tt.builderID)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_onViolation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		enforcement string
+		repo        string
+		expected    func(results.Verification) bool
+	}{
+		{
+			name:     "no enforcement",
+			expected: results.Verification.Fail,
+		},
+		{
+			name:        "org deny",
+			enforcement: `{"onViolation": "deny", "overwrite": {"default": "deny"}}`,
+			expected:    results.Verification.Fail,
+		},
+		{
+			name:        "org allow",
+			enforcement: `{"onViolation": "allow", "overwrite": {"default": "deny"}}`,
+			expected:    results.Verification.Audit,
+		},
+		{
+			name:        "org deny, repo allow without overwrite",
+			enforcement: `{"onViolation": "deny", "overwrite": {"default": "deny"}}`,
+			repo:        `{"onViolation": "allow"}`,
+			expected:    results.Verification.Fail,
+		},
+		{
+			name:        "org deny, repo allow with overwrite",
+			enforcement: `{"onViolation": "deny", "overwrite": {"default": "allow"}}`,
+			repo:        `{"onViolation": "allow"}`,
+			expected:    results.Verification.Audit,
+		},
+		{
+			name:        "org allow, repo deny with overwrite",
+			enforcement: `{"onViolation": "allow", "overwrite": {"default": "allow"}}`,
+			repo:        `{"onViolation": "deny"}`,
+			expected:    results.Verification.Fail,
+		},
+		{
+			name: "org deny, exception allow",
+			enforcement: `{
+				"onViolation": "deny",
+				"overwrite": {
+					"default": "deny",
+					"exceptions": [{"sources": [{"uri": "git+https://github.com/org/*"}], "onViolation": "allow"}]
+				}
+			}`,
+			expected: results.Verification.Audit,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			org := `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "images": [{"uri": "docker://org/*"}]}`
+			if tt.enforcement != "" {
+				org += `, "enforcement": ` + tt.enforcement
+			}
+			org += `}`
+			repo := `{"version": 1`
+			if tt.repo != "" {
+				repo += `, "enforcement": ` + tt.repo
+			}
+			repo += `}`
+			policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := policy.Evaluate("git+https://github.com/org/repo", "docker://other/image", "builder")
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_auditMode(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"mode": "audit",
+		"defaults": {
+			"tracks": {
+				"build": {"builders": [{"id": "https://github.com/builder", "level": 3}]},
+				"source": {"attestors": [{"id": "https://github.com/source-attestor"}]}
+			},
+			"images": [{"uri": "docker://org/*"}],
+			"sources": [{"uri": "git+https://github.com/org/*"}]
+		}
+	}`
+	repo := `{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}}]}`
+
+	tests := []struct {
+		name      string
+		imageURI  string
+		builderID string
+		opts      []options.Option
+		reasons   int
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "pass",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder",
+			opts:      []options.Option{options.WithSourceAttestation("https://github.com/source-attestor")},
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "one violation",
+			imageURI:  "docker://other/image",
+			builderID: "https://github.com/builder",
+			opts:      []options.Option{options.WithSourceAttestation("https://github.com/source-attestor")},
+			reasons:   1,
+			expected:  results.Verification.Audit,
+		},
+		{
+			name:      "all violations",
+			imageURI:  "docker://other/image",
+			builderID: "https://github.com/other-builder",
+			reasons:   3,
+			expected:  results.Verification.Audit,
+		},
+	}
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate("git+https://github.com/org/repo", tt.imageURI, tt.builderID, tt.opts...)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+			if diff := cmp.Diff(tt.reasons, len(result.Reasons())); diff != "" {
+				t.Fatalf("unexpected reasons (-want +got): %v\n%s", result, diff)
+			}
+		})
+	}
+}
+
+func Test_sourceTrackAuditMode(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"tracks": {
+				"build": {"builders": [{"id": "https://github.com/builder", "level": 3}]},
+				"source": {"attestors": [{"id": "https://github.com/source-attestor"}], "mode": "audit"}
+			},
+			"images": [{"uri": "docker://org/*"}],
+			"sources": [{"uri": "git+https://github.com/org/*"}]
+		},
+		"projects": [
+			{
+				"sources": [{"uri": "git+https://github.com/org/enforced"}],
+				"tracks": {"source": {"attestors": [{"id": "https://github.com/other-attestor"}], "mode": "enforce"}}
+			}
+		]
+	}`
+	repo := `{"version": 1}`
+
+	tests := []struct {
+		name      string
+		sourceURI string
+		imageURI  string
+		builderID string
+		opts      []options.Option
+		reasons   int
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "pass",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder",
+			opts:      []options.Option{options.WithSourceAttestation("https://github.com/source-attestor")},
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "no source attestation",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder",
+			reasons:   1,
+			expected:  results.Verification.Audit,
+		},
+		{
+			name:      "source attestor mismatch",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder",
+			opts:      []options.Option{options.WithSourceAttestation("https://github.com/other-attestor")},
+			reasons:   1,
+			expected:  results.Verification.Audit,
+		},
+		{
+			name:      "build track enforced",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/other-builder",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "project satisfied without violations",
+			sourceURI: "git+https://github.com/org/enforced",
+			imageURI:  "docker://org/image",
+			builderID: "https://github.com/builder",
+			opts:      []options.Option{options.WithSourceAttestation("https://github.com/other-attestor")},
+			expected:  results.Verification.Pass,
+		},
+	}
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate(tt.sourceURI, tt.imageURI, tt.builderID, tt.opts...)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+			if !result.Audit() {
+				return
+			}
+			if diff := cmp.Diff(tt.reasons, len(result.Reasons())); diff != "" {
+				t.Fatalf("unexpected reasons (-want +got): %v\n%s", result, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_rules(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"sources": [{"uri": "git+https://github.com/org/*"}],
+			"images": [{"uri": "docker://org/*"}],
+			"tracks": {"build": {"builders": [{"id": "https://github.com/builder", "level": 3}]}}
+		}
+	}`
+	repo := `{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "require_build_level": 3}]}`
+	policy, err := fromBytes([]string{"org.json", "repo.json"}, [][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		imageURI string
+		expected results.Result
+	}{
+		{
+			name:     "pass",
+			imageURI: "docker://org/image",
+			expected: results.Result{
+				Decision:   "pass",
+				BuildLevel: 3,
+				Matches: []results.Match{
+					{Policy: "org.json", Level: 0, Type: "org", Entry: "defaults"},
+					{Policy: "repo.json", Level: 1, Type: "repo", Entry: "projects[0]"},
+				},
+				Rules: []results.Rule{
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.sources[0].uri",
+						Pattern: "git+https://github.com/org/*", Value: "git+https://github.com/org/repo", Match: true,
+					},
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.images[0].uri",
+						Pattern: "docker.io/org/*", Value: "docker.io/org/image", Match: true,
+					},
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.tracks.build.builders[0].id",
+						Pattern: "https://github.com/builder", Value: "https://github.com/builder", Match: true,
+					},
+					{
+						Policy: "repo.json", Level: 1, Type: "repo", Entry: "projects[0]", Path: "projects[0].source.uri",
+						Pattern: "git+https://github.com/org/repo", Value: "git+https://github.com/org/repo", Match: true,
+					},
+					{
+						Policy: "repo.json", Level: 1, Type: "repo", Entry: "projects[0]", Path: "projects[0].require_build_level",
+						Pattern: "3", Value: "3", Match: true,
+					},
+				},
+			},
+		},
+		{
+			name:     "image mismatch",
+			imageURI: "docker://other/image",
+			expected: results.Result{
+				Decision: "fail",
+				Reasons: []string{
+					`"org": defaults: image uri mismatch: "docker.io/other/image"`,
+					`"repo": builder "https://github.com/builder" is level 0, project requires 3`,
+				},
+				Rules: []results.Rule{
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.sources[0].uri",
+						Pattern: "git+https://github.com/org/*", Value: "git+https://github.com/org/repo", Match: true,
+					},
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.images[0].uri",
+						Pattern: "docker.io/org/*", Value: "docker.io/other/image", Match: false,
+					},
+					{
+						Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.tracks.build.builders[0].id",
+						Pattern: "https://github.com/builder", Value: "https://github.com/builder", Match: true,
+					},
+					{
+						Policy: "repo.json", Level: 1, Type: "repo", Entry: "projects[0]", Path: "projects[0].source.uri",
+						Pattern: "git+https://github.com/org/repo", Value: "git+https://github.com/org/repo", Match: true,
+					},
+					{
+						Policy: "repo.json", Level: 1, Type: "repo", Entry: "projects[0]", Path: "projects[0].require_build_level",
+						Pattern: "3", Value: "0", Match: false,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate("git+https://github.com/org/repo", tt.imageURI, "https://github.com/builder")
+			if diff := cmp.Diff(tt.expected, result.Result()); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+			content, err := json.Marshal(result)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			var decoded results.Result
+			if err := json.Unmarshal(content, &decoded); err != nil {
+				t.Fatalf("failed to unmarshal: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, decoded); diff != "" {
+				t.Fatalf("unexpected JSON result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_subjects(t *testing.T) {
+	t.Parallel()
+
+	org := `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "images": [{"uri": "docker://org/image:*"}]}}`
+	repo := `{"version": 1}`
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	subjects := []intoto.ResourceDescriptor{
+		{Name: "docker.io/org/image", Digest: map[string]string{"sha256": "abcd"}},
+	}
+
+	tests := []struct {
+		name     string
+		imageURI string
+		subjects []intoto.ResourceDescriptor
+		expected func(results.Verification) bool
+	}{
+		{
+			name:     "no subjects",
+			imageURI: "docker://org/image:v1",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "digest in subjects",
+			imageURI: "docker://org/image:v1@sha256:abcd",
+			subjects: subjects,
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "image mismatch",
+			imageURI: "docker://org/other:v1@sha256:abcd",
+			subjects: subjects,
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "digest not in subjects",
+			imageURI: "docker://org/image:v1@sha256:efgh",
+			subjects: subjects,
+			expected: results.Verification.Invalid,
+		},
+		{
+			name:     "no digest",
+			imageURI: "docker://org/image:v1",
+			subjects: subjects,
+			expected: results.Verification.Invalid,
+		},
+		{
+			name:     "digest not sha256",
+			imageURI: "docker://org/image:v1@sha512:abcd",
+			subjects: []intoto.ResourceDescriptor{
+				{Name: "docker.io/org/image", Digest: map[string]string{"sha512": "abcd"}},
+			},
+			expected: results.Verification.Invalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []options.Option
+			if tt.subjects != nil {
+				opts = append(opts, options.WithSubjects(tt.subjects))
+			}
+			result := policy.Evaluate("git+https://github.com/org/repo", tt.imageURI, "builder", opts...)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_imageNormalization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		pattern  string
+		imageURI string
+		expected func(results.Verification) bool
+	}{
+		{
+			name:     "short name",
+			pattern:  "nginx",
+			imageURI: "docker.io/library/nginx",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "docker scheme",
+			pattern:  "docker.io/library/nginx",
+			imageURI: "docker://nginx",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "registry alias",
+			pattern:  "docker://nginx",
+			imageURI: "index.docker.io/library/nginx",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "namespace glob",
+			pattern:  "docker://org/*",
+			imageURI: "docker.io/org/image:v1",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "uppercase registry",
+			pattern:  "ghcr.io/org/*",
+			imageURI: "GHCR.IO/org/image",
+			expected: results.Verification.Pass,
+		},
+		{
+			name:     "other registry",
+			pattern:  "nginx",
+			imageURI: "ghcr.io/library/nginx",
+			expected: results.Verification.Fail,
+		},
+		{
+			name:     "invalid image",
+			pattern:  "nginx",
+			imageURI: "nginx:in valid",
+			expected: results.Verification.Invalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			org := `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "images": [{"uri": "` + tt.pattern + `"}]}}`
+			repo := `{"version": 1}`
+			policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := policy.Evaluate("git+https://github.com/org/repo", tt.imageURI, "builder")
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_sourceNormalization(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		pattern   string
+		sourceURI string
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "bare host path",
+			pattern:   "git+https://github.com/org/*",
+			sourceURI: "github.com/org/repo",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "https and .git suffix",
+			pattern:   "github.com/org/repo",
+			sourceURI: "https://github.com/org/repo.git",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "any ref",
+			pattern:   "git+https://github.com/org/repo",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/main",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "branch ref",
+			pattern:   "git+https://github.com/org/repo@refs/heads/main",
+			sourceURI: "github.com/org/repo@refs/heads/main",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "tag ref",
+			pattern:   "git+https://github.com/org/repo@refs/tags/*",
+			sourceURI: "https://github.com/org/repo@refs/tags/v1.0.0",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "ref mismatch",
+			pattern:   "git+https://github.com/org/repo@refs/heads/main",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/feature",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "no ref",
+			pattern:   "git+https://github.com/org/repo@refs/heads/main",
+			sourceURI: "git+https://github.com/org/repo",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "ref does not match the repository",
+			pattern:   "git+https://github.com/org/repo",
+			sourceURI: "git+https://github.com/org/repo-evil@refs/heads/main",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "invalid source",
+			pattern:   "git+https://github.com/org/repo",
+			sourceURI: "git+https://github.com/org/repo@main",
+			expected:  results.Verification.Invalid,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			org := `{"version": 1, "defaults": {"sources": [{"uri": "` + tt.pattern + `"}]}}`
+			repo := `{"version": 1}`
+			policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := policy.Evaluate(tt.sourceURI, "docker.io/org/image", "builder")
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_VerifyAttestation(t *testing.T) {
+	t.Parallel()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	key, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	otherDER, err := x509.MarshalPKIXPublicKey(otherPublic)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	other, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: otherDER})))
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	payload := []byte(`{
+		"_type": "https://in-toto.io/Statement/v1",
+		"subject": [{"name": "docker.io/org/image", "digest": {"sha256": "abcd"}}],
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"predicate": {
+			"buildDefinition": {"externalParameters": {"workflow": {"ref": "refs/heads/main", "repository": "https://github.com/org/repo"}}},
+			"runDetails": {"builder": {"id": "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.0.0"}}
+		}
+	}`)
+	envelope, err := json.Marshal(dsse.Envelope{
+		PayloadType: dsse.PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []dsse.Signature{
+			{Sig: base64.StdEncoding.EncodeToString(ed25519.Sign(private, dsse.PAE(dsse.PayloadType, payload)))},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+
+	defaults := `"defaults": {"sources": [{"uri": "*"}]}`
+	builders := `"builder_ids": ["https://github.com/org/.github/workflows/builder.yml@*"]`
+	tests := []struct {
+		name     string
+		policies []string
+		loadErr  bool
+		err      bool
+		noTrust  bool
+	}{
+		{
+			name:     "trusted key",
+			policies: []string{`{"version": 1, ` + defaults + `, "trust": {"roots": [{"id": "key", "public_key": ` + string(key) + `, ` + builders + `}]}}`, `{"version": 1}`},
+		},
+		{
+			name: "builder not allowed for the trust root",
+			policies: []string{
+				`{"version": 1, ` + defaults + `, "trust": {"roots": [
+					{"id": "other", "public_key": ` + string(other) + `, ` + builders + `},
+					{"id": "key", "public_key": ` + string(key) + `, "builder_ids": ["https://cloudbuild.googleapis.com/GoogleHostedWorker"]}
+				]}}`,
+				`{"version": 1}`,
+			},
+			err: true,
+		},
+		{
+			name: "builder predicate",
+			policies: []string{
+				`{"version": 1, ` + defaults + `, "trust": {"roots": [
+					{"id": "key", "public_key": ` + string(key) + `, "builder_ids": [{"cel": "builder.startsWith('https://github.com/org/')"}]}
+				]}}`,
+				`{"version": 1}`,
+			},
+		},
+		{
+			name:     "empty builder IDs",
+			policies: []string{`{"version": 1, ` + defaults + `, "trust": {"roots": [{"id": "key", "public_key": ` + string(key) + `}]}}`, `{"version": 1}`},
+			loadErr:  true,
+		},
+		{
+			name: "duplicate trust roots",
+			policies: []string{
+				`{"version": 1, ` + defaults + `, "trust": {"roots": [
+					{"id": "key", "public_key": ` + string(key) + `, ` + builders + `},
+					{"id": "key", "public_key": ` + string(other) + `, ` + builders + `}
+				]}}`,
+				`{"version": 1}`,
+			},
+			loadErr: true,
+		},
+		{
+			name:     "no trust roots",
+			policies: []string{`{"version": 1, ` + defaults + `}`, `{"version": 1}`},
+			err:      true,
+			noTrust:  true,
+		},
+		{
+			name:     "invalid trust root",
+			policies: []string{`{"version": 1, ` + defaults + `, "trust": {"roots": [{"id": "key", "public_key": "key", ` + builders + `}]}}`, `{"version": 1}`},
+			loadErr:  true,
+		},
+		{
+			name: "trust roots in a unit policy",
+			policies: []string{
+				`{"version": 1, ` + defaults + `}`,
+				`{"version": 1, "type": "unit", ` + defaults + `, "trust": {"roots": [{"id": "key", "public_key": ` + string(key) + `, ` + builders + `}]}}`,
+				`{"version": 1}`,
+			},
+			loadErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := make([][]byte, len(tt.policies))
+			for i := range tt.policies {
+				content[i] = []byte(tt.policies[i])
+			}
+			policy, err := FromBytes(content)
+			if diff := cmp.Diff(tt.loadErr, err != nil); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if err != nil {
+				return
+			}
+			verified, err := policy.VerifyAttestation(envelope)
+			if diff := cmp.Diff(tt.err, err != nil); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if diff := cmp.Diff(tt.noTrust, errors.Is(err, ErrNoTrustRoots)); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(string(payload), string(verified)); diff != "" {
+				t.Fatalf("unexpected payload (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
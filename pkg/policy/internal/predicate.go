@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
+)
+
+// Pattern is the value of a uri or id field. It is either a glob,
+// given as a string, or a predicate, given as {"regex": "..."}
+// or {"cel": "..."}.
+type Pattern struct {
+	// Glob is matched in the pattern syntax of the policy.
+	Glob string
+	// Regex must match the entire value.
+	Regex string
+	// CEL is an expression over the evaluation input that must
+	// evaluate to true. See celEnv for its variables.
+	CEL string
+
+	// predicate is the compiled Regex or CEL, set when the policy is loaded.
+	predicate predicate
+	// err is the error of a malformed predicate object. It is reported
+	// when the pattern is compiled, with the path of its field.
+	err error
+}
+
+// UnmarshalJSON unmarshals a glob string or a predicate object.
+// Predicates are validated and compiled when the policy is validated.
+func (p *Pattern) UnmarshalJSON(data []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return json.Unmarshal(data, &p.Glob)
+	}
+	var predicate struct {
+		Regex string `json:"regex"`
+		CEL   string `json:"cel"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	switch err := decoder.Decode(&predicate); {
+	case err != nil:
+		p.err = err
+	case predicate.Regex == "" && predicate.CEL == "":
+		p.err = errors.New("empty regex and cel")
+	}
+	p.Regex, p.CEL = predicate.Regex, predicate.CEL
+	return nil
+}
+
+// isPredicate returns true if the pattern is a regex or a CEL expression.
+func (p Pattern) isPredicate() bool {
+	return p.Regex != "" || p.CEL != ""
+}
+
+// empty returns true if the pattern is not set.
+func (p Pattern) empty() bool {
+	return p.Glob == "" && !p.isPredicate() && p.err == nil
+}
+
+func (p Pattern) String() string {
+	switch {
+	case p.Regex != "":
+		return fmt.Sprintf("regex: %s", p.Regex)
+	case p.CEL != "":
+		return fmt.Sprintf("cel: %s", p.CEL)
+	default:
+		return p.Glob
+	}
+}
+
+// compile validates the pattern and compiles its predicate, if any.
+func (p *Pattern) compile(syn syntax) error {
+	switch {
+	case p.err != nil:
+		return p.err
+	case p.Regex != "" && p.CEL != "":
+		return errors.New("both regex and cel")
+	case p.Regex != "":
+		predicate, err := compileRegex(p.Regex)
+		if err != nil {
+			return err
+		}
+		p.predicate = predicate
+	case p.CEL != "":
+		predicate, err := compileCEL(p.CEL)
+		if err != nil {
+			return err
+		}
+		p.predicate = predicate
+	default:
+		return syn.validate(p.Glob)
+	}
+	return nil
+}
+
+// match tests the pattern against a value. Globs are matched
+// with glob, predicates against the value and the input.
+func (p Pattern) match(glob func(pattern, value string) bool, value string, in *input) bool {
+	if p.predicate == nil {
+		return glob(p.Glob, value)
+	}
+	return p.predicate.matches(value, in)
+}
+
+// coversPattern returns true if every value matched by the child pattern
+// is also matched by the parent pattern. Predicates are opaque: a predicate
+// is only covered by the same predicate or by a glob that matches anything,
+// and a predicate only covers the same predicate.
+func coversPattern(c coverage, parent, child Pattern, covers func(c coverage, parent, child string) bool) bool {
+	switch {
+	case !parent.isPredicate() && !child.isPredicate():
+		return covers(c, parent.Glob, child.Glob)
+	case !parent.isPredicate():
+		return c.covers(parent.Glob, c.child.any())
+	default:
+		return parent.Regex == child.Regex && parent.CEL == child.CEL
+	}
+}
+
+// input is the evaluation input that CEL predicates are evaluated against.
+type input struct {
+	source      string
+	image       string
+	builder     string
+	attestor    string
+	environment string
+	labels      map[string]string
+}
+
+func newInput(sourceURI, imageURI, builderID string, evaluation options.Evaluation) *input {
+	return &input{
+		source:      sourceURI,
+		image:       imageURI,
+		builder:     builderID,
+		attestor:    evaluation.SourceAttestorID,
+		environment: evaluation.Environment,
+		labels:      evaluation.Labels,
+	}
+}
+
+func (in *input) variables() map[string]any {
+	labels := in.labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return map[string]any{
+		"source":      in.source,
+		"image":       in.image,
+		"builder":     in.builder,
+		"attestor":    in.attestor,
+		"environment": in.environment,
+		"labels":      labels,
+	}
+}
+
+// predicate is a compiled regex or CEL expression.
+type predicate interface {
+	matches(value string, in *input) bool
+}
+
+type regexPredicate struct {
+	re *regexp.Regexp
+}
+
+func compileRegex(expr string) (*regexPredicate, error) {
+	// Compile the expression alone first, for errors to refer to it.
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("regex: %w", err)
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("regex: %w", err)
+	}
+	return &regexPredicate{re: re}, nil
+}
+
+func (r *regexPredicate) matches(value string, _ *input) bool {
+	return r.re.MatchString(value)
+}
+
+type celPredicate struct {
+	program cel.Program
+}
+
+var (
+	celEnvOnce sync.Once
+	celEnvErr  error
+	celEnvVal  *cel.Env
+)
+
+// celEnv returns the environment of CEL predicates. Its variables are
+// the source, image, builder and source attestor of the evaluation,
+// all in their canonical form, its environment, and its labels.
+func celEnv() (*cel.Env, error) {
+	celEnvOnce.Do(func() {
+		celEnvVal, celEnvErr = cel.NewEnv(
+			cel.Variable("source", cel.StringType),
+			cel.Variable("image", cel.StringType),
+			cel.Variable("builder", cel.StringType),
+			cel.Variable("attestor", cel.StringType),
+			cel.Variable("environment", cel.StringType),
+			cel.Variable("labels", cel.MapType(cel.StringType, cel.StringType)),
+		)
+	})
+	return celEnvVal, celEnvErr
+}
+
+func compileCEL(expr string) (*celPredicate, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, fmt.Errorf("cel: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("cel: %w", issues.Err())
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("cel: expression of type %s, expected bool", ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("cel: %w", err)
+	}
+	return &celPredicate{program: program}, nil
+}
+
+// matches returns true if the expression evaluates to true.
+// Evaluation errors, e.g. a missing label, do not match.
+func (c *celPredicate) matches(_ string, in *input) bool {
+	out, _, err := c.program.Eval(in.variables())
+	if err != nil {
+		return false
+	}
+	match, ok := out.Value().(bool)
+	return ok && match
+}
@@ -0,0 +1,308 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_FromBytes_predicates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		policies []string
+		expected bool
+	}{
+		{
+			name: "regex and cel",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": {"regex": "git\\+https://github\\.com/org/[a-z]+"}}],
+					"tracks": {"build": {"builders": [{"id": {"cel": "builder.endsWith('@refs/tags/v1.0.0')"}, "level": 3}]}}}}`,
+				`{"version": 1, "projects": [{"source": {"uri": {"regex": "git\\+https://github\\.com/org/[a-z]+"}}, "require_build_level": 3}]}`,
+			},
+			expected: true,
+		},
+		{
+			name:     "invalid regex",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"regex": "git+https://(github.com"}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "invalid cel",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"cel": "source.startsWith("}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "unknown cel variable",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"cel": "repo == 'x'"}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "cel not a bool",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"cel": "source"}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "regex and cel in one pattern",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"regex": ".*", "cel": "true"}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "empty predicate",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "unknown predicate",
+			policies: []string{`{"version": 1, "defaults": {"sources": [{"uri": {"rego": "true"}}]}}`, `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name: "invalid repo predicate",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "image": {"uri": {"regex": "("}}}]}`,
+			},
+			expected: false,
+		},
+		{
+			name: "invalid exception predicate",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "*"}]},
+					"enforcement": {"onViolation": "deny", "overwrite": {"default": "deny", "exceptions": [{"sources": [{"uri": {"cel": "1"}}]}]}}}`,
+				`{"version": 1}`,
+			},
+			expected: false,
+		},
+		{
+			name: "predicate within a glob that matches anything",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": {"cel": "source.startsWith('git+https://github.com/org/')"}}}]}`,
+			},
+			expected: true,
+		},
+		{
+			name: "predicate widens a glob",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}]}}`,
+				`{"version": 1, "projects": [{"source": {"uri": {"regex": "git\\+https://github\\.com/org/.*"}}}]}`,
+			},
+			expected: false,
+		},
+		{
+			name: "glob widens a predicate",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "*"}], "images": [{"uri": {"regex": "ghcr\\.io/org/.*"}}]}}`,
+				`{"version": 1, "type": "unit", "defaults": {"sources": [{"uri": "*"}], "images": [{"uri": "ghcr.io/org/image"}]}}`,
+				`{"version": 1}`,
+			},
+			expected: false,
+		},
+		{
+			name: "same predicate",
+			policies: []string{
+				`{"version": 1, "defaults": {"sources": [{"uri": "*"}], "images": [{"uri": {"regex": "ghcr\\.io/org/.*"}}]}}`,
+				`{"version": 1, "type": "unit", "defaults": {"sources": [{"uri": "*"}], "images": [{"uri": {"regex": "ghcr\\.io/org/.*"}}]}}`,
+				`{"version": 1}`,
+			},
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := make([][]byte, len(tt.policies))
+			for i := range tt.policies {
+				content[i] = []byte(tt.policies[i])
+			}
+			_, err := FromBytes(content)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_FromFiles_predicateError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		org      string
+		repo     string
+		expected []string
+	}{
+		{
+			name: "invalid cel",
+			org: `{"version": 1, "defaults": {"sources": [{"uri": "*"}],
+				"tracks": {"build": {"builders": [{"id": {"cel": "builder =="}}]}}}}`,
+			repo:     `{"version": 1}`,
+			expected: []string{"org.json", `"tracks.build.builders[0].id"`},
+		},
+		{
+			name:     "empty regex",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": {"regex": ""}}]}}`,
+			repo:     `{"version": 1}`,
+			expected: []string{"org.json", `"sources[0].uri"`, "empty regex and cel"},
+		},
+		{
+			name: "unknown predicate",
+			org: `{"version": 1, "defaults": {"sources": [{"uri": "*"}],
+				"tracks": {"source": {"attestors": [{"id": {"rego": "true"}}]}}}}`,
+			repo:     `{"version": 1}`,
+			expected: []string{"org.json", `"tracks.source.attestors[0].id"`, `"rego"`},
+		},
+		{
+			name:     "unknown repo predicate",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}]}}`,
+			repo:     `{"version": 1, "projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "image": {"uri": {"re": "x"}}}]}`,
+			expected: []string{"repo.json", `"image.uri"`, `"re"`},
+		},
+		{
+			name:     "invalid org json",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": 1}]}}`,
+			repo:     `{"version": 1}`,
+			expected: []string{"org.json", "failed to unmarshal"},
+		},
+		{
+			name:     "invalid repo json",
+			org:      `{"version": 1, "defaults": {"sources": [{"uri": "*"}]}}`,
+			repo:     `{"version": "1"}`,
+			expected: []string{"repo.json", "failed to unmarshal"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			org := filepath.Join(dir, "org.json")
+			repo := filepath.Join(dir, "repo.json")
+			if err := os.WriteFile(org, []byte(tt.org), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := os.WriteFile(repo, []byte(tt.repo), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			_, err := FromFiles([]string{org, repo})
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			for _, want := range tt.expected {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_Evaluate_predicates(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"sources": [{"uri": {"regex": "git\\+https://github\\.com/org/[a-z]+(@refs/tags/v[0-9]+\\.[0-9]+\\.[0-9]+)?"}}],
+			"tracks": {
+				"build": {
+					"builders": [{"id": {"regex": ".*/\\.github/workflows/builder\\.yml@refs/tags/v\\d+\\.\\d+\\.\\d+"}, "level": 3}]
+				}
+			}
+		},
+		"projects": [
+			{
+				"sources": [{"uri": {"cel": "source.startsWith('git+https://github.com/org/prod-') && environment == 'prod'"}}],
+				"images": [{"uri": {"cel": "image.startsWith('docker.io/org/') && labels['team'] == 'infra'"}}]
+			}
+		]
+	}`
+	tests := []struct {
+		name      string
+		sourceURI string
+		imageURI  string
+		builderID string
+		opts      []options.Option
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "regex match",
+			sourceURI: "git+https://github.com/org/repo@refs/tags/v1.2.3",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "regex is anchored",
+			sourceURI: "git+https://github.com/org/repo",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3-evil",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "regex source mismatch",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/main",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "cel match",
+			sourceURI: "git+https://github.com/org/prod-repo",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			opts:      []options.Option{options.WithEnvironment("prod"), options.WithLabels(map[string]string{"team": "infra"})},
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "cel label mismatch",
+			sourceURI: "git+https://github.com/org/prod-repo",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			opts:      []options.Option{options.WithEnvironment("prod"), options.WithLabels(map[string]string{"team": "web"})},
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "cel missing label",
+			sourceURI: "git+https://github.com/org/prod-repo",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			opts:      []options.Option{options.WithEnvironment("prod")},
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "cel environment mismatch",
+			sourceURI: "git+https://github.com/org/prod-repo",
+			imageURI:  "org/image",
+			builderID: "https://github.com/org/.github/workflows/builder.yml@refs/tags/v1.2.3",
+			opts:      []options.Option{options.WithEnvironment("staging"), options.WithLabels(map[string]string{"team": "infra"})},
+			expected:  results.Verification.Fail,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy, err := FromBytes([][]byte{[]byte(org), []byte(`{"version": 1}`)})
+			if err != nil {
+				t.Fatalf("failed to create policy: %v", err)
+			}
+			result := policy.Evaluate(tt.sourceURI, tt.imageURI, tt.builderID, tt.opts...)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
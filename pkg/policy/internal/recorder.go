@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+// recorder records the rules checked during an evaluation.
+// A nil recorder records nothing.
+type recorder struct {
+	rules   []results.Rule
+	matches []results.Match
+}
+
+// scope is the location of an entry within the hierarchy.
+type scope struct {
+	// policy is the file of the policy, if known.
+	policy  string
+	level   int
+	context context
+	// entry is the path of the entry within the policy.
+	entry string
+	// syntax is the pattern syntax of the policy.
+	syntax syntax
+}
+
+// entryScope returns the scope of the i-th entry of a level,
+// the defaults being the first entry.
+func entryScope(lvl level, index, i int) scope {
+	entry := "defaults"
+	if i > 0 {
+		entry = fmt.Sprintf("projects[%d]", i-1)
+	}
+	return scope{policy: lvl.name, level: index, context: lvl.context, entry: entry, syntax: syntaxOf(lvl.policy.Version)}
+}
+
+// rule records a rule at path within the entry of s, and returns match.
+func (r *recorder) rule(s scope, path, pattern, value string, match bool) bool {
+	return r.ruleAt(s, s.entry+"."+path, pattern, value, match)
+}
+
+// ruleAt records a rule of the entry of s at a path within the policy,
+// and returns match. The path is outside the entry for the items
+// that the entry inherits from the defaults.
+func (r *recorder) ruleAt(s scope, path, pattern, value string, match bool) bool {
+	if r == nil {
+		return match
+	}
+	r.rules = append(r.rules, results.Rule{
+		Policy:  s.policy,
+		Level:   s.level,
+		Type:    string(s.context),
+		Entry:   s.entry,
+		Path:    path,
+		Pattern: pattern,
+		Value:   value,
+		Match:   match,
+	})
+	return match
+}
+
+// itemPath returns the path of an item of a list of the entry of s
+// within the policy: its origin if known, or its path within the entry.
+func itemPath(s scope, origin, path string) string {
+	if origin != "" {
+		return origin
+	}
+	return s.entry + "." + path
+}
+
+// match records that the entry of s is satisfied.
+func (r *recorder) match(s scope) {
+	if r == nil {
+		return
+	}
+	r.matches = append(r.matches, results.Match{
+		Policy: s.policy,
+		Level:  s.level,
+		Type:   string(s.context),
+		Entry:  s.entry,
+	})
+}
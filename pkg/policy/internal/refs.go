@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/sourceuri"
+)
+
+// Refs restricts the refs the sources of an entry are built from.
+// A branch is allowed if it matches one of Branches. A tag is allowed
+// if it matches one of Tags, if any, and its version is within the
+// Semver range, if any. Commits are never allowed, since the branch
+// or the tag they were built from is unknown.
+type Refs struct {
+	Branches []string `json:"branches"`
+	Tags     []string `json:"tags"`
+	// Semver is a range of tag versions, e.g. ">=1.0.0 <2.0.0".
+	Semver string `json:"semver"`
+
+	// semver is the parsed Semver range, set when the policy is loaded.
+	semver *versionRange
+}
+
+// validateRefs validates the refs and parses their semver range.
+func validateRefs(refs *Refs) error {
+	if refs == nil {
+		return nil
+	}
+	if len(refs.Branches) == 0 && len(refs.Tags) == 0 && refs.Semver == "" {
+		return fmt.Errorf("empty %q", "refs")
+	}
+	for i := range refs.Branches {
+		if refs.Branches[i] == "" {
+			return fmt.Errorf("refs: empty %q", "branches")
+		}
+	}
+	for i := range refs.Tags {
+		if refs.Tags[i] == "" {
+			return fmt.Errorf("refs: empty %q", "tags")
+		}
+	}
+	if refs.Semver == "" {
+		return nil
+	}
+	semver, err := parseVersionRange(refs.Semver)
+	if err != nil {
+		return fmt.Errorf("refs: invalid %q: %w", "semver", err)
+	}
+	refs.semver = semver
+	return nil
+}
+
+// allowsTags returns true if some tags are allowed.
+func (r *Refs) allowsTags() bool {
+	return len(r.Tags) > 0 || r.Semver != ""
+}
+
+// matches returns true if the ref of the source is allowed.
+// Other refs than branches and tags, e.g. refs/pull/1/merge,
+// and commits are not allowed.
+func (r *Refs) matches(syn syntax, source sourceuri.Reference) bool {
+	if branch := source.Branch(); branch != "" {
+		return globAny(syn, r.Branches, branch)
+	}
+	tag := source.Tag()
+	if tag == "" || !r.allowsTags() {
+		return false
+	}
+	if len(r.Tags) > 0 && !globAny(syn, r.Tags, tag) {
+		return false
+	}
+	if r.semver == nil {
+		return true
+	}
+	v, err := parseVersion(tag)
+	return err == nil && r.semver.contains(v)
+}
+
+// within returns true if every ref allowed by r is allowed by parent.
+func (r *Refs) within(c coverage, parent *Refs) bool {
+	for i := range r.Branches {
+		if !coveredAny(c, parent.Branches, r.Branches[i]) {
+			return false
+		}
+	}
+	if !r.allowsTags() {
+		return true
+	}
+	if !parent.allowsTags() {
+		return false
+	}
+	if len(parent.Tags) > 0 {
+		if len(r.Tags) == 0 {
+			return false
+		}
+		for i := range r.Tags {
+			if !coveredAny(c, parent.Tags, r.Tags[i]) {
+				return false
+			}
+		}
+	}
+	if parent.semver != nil {
+		return r.semver != nil && r.semver.within(*parent.semver)
+	}
+	return true
+}
+
+func (r *Refs) String() string {
+	var parts []string
+	if len(r.Branches) > 0 {
+		parts = append(parts, "branches: "+strings.Join(r.Branches, ", "))
+	}
+	if len(r.Tags) > 0 {
+		parts = append(parts, "tags: "+strings.Join(r.Tags, ", "))
+	}
+	if r.Semver != "" {
+		parts = append(parts, "semver: "+r.Semver)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func globAny(syn syntax, patterns []string, s string) bool {
+	for i := range patterns {
+		if syn.match(patterns[i], s) {
+			return true
+		}
+	}
+	return false
+}
+
+func coveredAny(c coverage, parents []string, child string) bool {
+	for i := range parents {
+		if c.covers(parents[i], child) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyRefs verifies that the ref of the source is allowed by the refs, if any.
+func verifyRefs(rec *recorder, s scope, refs *Refs, sourceURI string) error {
+	if refs == nil {
+		return nil
+	}
+	// NOTE: the source was validated before the evaluation.
+	source := sourceuri.ParsePattern(sourceURI)
+	if rec.rule(s, "refs", refs.String(), source.Ref, refs.matches(s.syntax, source)) {
+		return nil
+	}
+	if source.Ref == "" {
+		return fmt.Errorf("source %q has no ref", sourceURI)
+	}
+	return fmt.Errorf("source ref %q is not allowed", source.Ref)
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_FromBytes_refs(t *testing.T) {
+	t.Parallel()
+
+	org := func(refs string) string {
+		return `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "refs": ` + refs + `}}`
+	}
+	tests := []struct {
+		name     string
+		policies []string
+		expected bool
+	}{
+		{
+			name:     "branches, tags and semver",
+			policies: []string{org(`{"branches": ["main", "release/*"], "tags": ["v*"], "semver": ">=1.0.0 <2.0.0"}`), `{"version": 1}`},
+			expected: true,
+		},
+		{
+			name:     "empty refs",
+			policies: []string{org(`{}`), `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "empty branch",
+			policies: []string{org(`{"branches": [""]}`), `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name:     "invalid semver",
+			policies: []string{org(`{"semver": ">=2.0.0 <1.0.0"}`), `{"version": 1}`},
+			expected: false,
+		},
+		{
+			name: "narrow the branches",
+			policies: []string{org(`{"branches": ["main", "release/*"]}`), `{
+				"version": 1,
+				"projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "refs": {"branches": ["release/v1"]}}]
+			}`},
+			expected: true,
+		},
+		{
+			name: "widen the branches",
+			policies: []string{org(`{"branches": ["main"]}`), `{
+				"version": 1,
+				"projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "refs": {"branches": ["feature/*"]}}]
+			}`},
+			expected: false,
+		},
+		{
+			name: "allow tags",
+			policies: []string{org(`{"branches": ["main"]}`), `{
+				"version": 1,
+				"projects": [{"source": {"uri": "git+https://github.com/org/repo"}, "refs": {"tags": ["v*"]}}]
+			}`},
+			expected: false,
+		},
+		{
+			name: "narrow the semver range",
+			policies: []string{org(`{"semver": ">=1.0.0"}`), `{
+				"version": 1, "type": "unit",
+				"defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "refs": {"semver": ">=1.2.0 <2.0.0"}}
+			}`, `{"version": 1}`},
+			expected: true,
+		},
+		{
+			name: "widen the semver range",
+			policies: []string{org(`{"semver": ">=1.0.0 <2.0.0"}`), `{
+				"version": 1, "type": "unit",
+				"defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "refs": {"semver": ">=1.0.0"}}
+			}`, `{"version": 1}`},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := make([][]byte, len(tt.policies))
+			for i := range tt.policies {
+				content[i] = []byte(tt.policies[i])
+			}
+			_, err := FromBytes(content)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_refs(t *testing.T) {
+	t.Parallel()
+
+	org := `{
+		"version": 1,
+		"defaults": {
+			"sources": [{"uri": "git+https://github.com/org/*"}],
+			"refs": {"branches": ["main", "release/*"], "semver": ">=1.0.0 <2.0.0"}
+		}
+	}`
+	repo := `{
+		"version": 1,
+		"projects": [
+			{"source": {"uri": "git+https://github.com/org/repo"}},
+			{"source": {"uri": "git+https://github.com/org/tagged"}, "refs": {"tags": ["v1.*"], "semver": ">=1.0.0 <1.5.0"}}
+		]
+	}`
+	policy, err := FromBytes([][]byte{[]byte(org), []byte(repo)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sourceURI string
+		expected  func(results.Verification) bool
+	}{
+		{
+			name:      "main branch",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/main",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "release branch",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/release/v1",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "feature branch",
+			sourceURI: "git+https://github.com/org/repo@refs/heads/feature/x",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "tag in range",
+			sourceURI: "git+https://github.com/org/repo@refs/tags/v1.2.3",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "tag out of range",
+			sourceURI: "git+https://github.com/org/repo@refs/tags/v2.0.0",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "prerelease tag",
+			sourceURI: "git+https://github.com/org/repo@refs/tags/v1.3.0-rc.1",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "tag not a version",
+			sourceURI: "git+https://github.com/org/repo@refs/tags/latest",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "commit",
+			sourceURI: "git+https://github.com/org/repo@2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "pull request ref",
+			sourceURI: "git+https://github.com/org/repo@refs/pull/1/merge",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "no ref",
+			sourceURI: "git+https://github.com/org/repo",
+			expected:  results.Verification.Fail,
+		},
+		{
+			name:      "repo tag",
+			sourceURI: "git+https://github.com/org/tagged@refs/tags/v1.0.0",
+			expected:  results.Verification.Pass,
+		},
+		{
+			name:      "repo does not allow branches",
+			sourceURI: "git+https://github.com/org/tagged@refs/heads/main",
+			expected:  results.Verification.Fail,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate(tt.sourceURI, "docker.io/org/image", "builder")
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_refs_rules(t *testing.T) {
+	t.Parallel()
+
+	org := `{"version": 1, "defaults": {"sources": [{"uri": "git+https://github.com/org/*"}], "refs": {"branches": ["main"], "tags": ["v*"]}}}`
+	policy, err := fromBytes([]string{"org.json", "repo.json"}, [][]byte{[]byte(org), []byte(`{"version": 1}`)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	result := policy.Evaluate("git+https://github.com/org/repo@refs/heads/feature", "docker.io/org/image", "builder")
+	expected := results.Result{
+		Decision: "fail",
+		Reasons:  []string{`"org": defaults: source ref "refs/heads/feature" is not allowed`},
+		Rules: []results.Rule{
+			{
+				Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.sources[0].uri",
+				Pattern: "git+https://github.com/org/*", Value: "git+https://github.com/org/repo@refs/heads/feature", Match: true,
+			},
+			{
+				Policy: "org.json", Level: 0, Type: "org", Entry: "defaults", Path: "defaults.refs",
+				Pattern: "branches: main; tags: v*", Value: "refs/heads/feature", Match: false,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, result.Result()); diff != "" {
+		t.Fatalf("unexpected result (-want +got): \n%s", diff)
+	}
+}
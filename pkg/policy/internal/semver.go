@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version. Build metadata is ignored.
+type version struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+// parseVersion parses a version of the form [v]major.minor.patch[-prerelease][+build].
+func parseVersion(s string) (version, error) {
+	var v version
+	core := strings.TrimPrefix(s, "v")
+	core, _, _ = strings.Cut(core, "+")
+	core, prerelease, hasPrerelease := strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	for i := range parts {
+		n, err := parseNumber(parts[i])
+		if err != nil {
+			return version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+	if hasPrerelease {
+		v.prerelease = strings.Split(prerelease, ".")
+		for _, identifier := range v.prerelease {
+			if identifier == "" {
+				return version{}, fmt.Errorf("invalid version %q", s)
+			}
+		}
+	}
+	return v, nil
+}
+
+func parseNumber(s string) (int, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to
+// or greater than o, following the semver precedence rules.
+func (v version) compare(o version) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	// A version without a prerelease has the higher precedence.
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := compareIdentifiers(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.prerelease) - len(o.prerelease))
+}
+
+// compareIdentifiers compares prerelease identifiers: numeric
+// identifiers compare numerically and are lower than the others.
+func compareIdentifiers(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(na - nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func sign(d int) int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// bound is a bound of a version range.
+type bound struct {
+	version   version
+	inclusive bool
+}
+
+// versionRange is a range of versions, e.g. >=1.0.0 <2.0.0.
+// A nil bound is unbounded.
+type versionRange struct {
+	lower, upper *bound
+	// prerelease is true if the range contains prerelease versions,
+	// which is only the case if one of its comparators is a prerelease.
+	prerelease bool
+}
+
+// parseVersionRange parses space-separated comparators, all of which
+// must be satisfied. A comparator is an operator among >=, >, <=, <
+// and =, followed by a version. A version without an operator is exact.
+func parseVersionRange(s string) (*versionRange, error) {
+	comparators := strings.Fields(s)
+	if len(comparators) == 0 {
+		return nil, fmt.Errorf("empty range")
+	}
+	var r versionRange
+	for _, comparator := range comparators {
+		op, value := splitOperator(comparator)
+		v, err := parseVersion(value)
+		if err != nil {
+			return nil, fmt.Errorf("range %q: %w", s, err)
+		}
+		if len(v.prerelease) > 0 {
+			r.prerelease = true
+		}
+		switch op {
+		case ">=", ">":
+			r.raiseLower(bound{version: v, inclusive: op == ">="})
+		case "<=", "<":
+			r.lowerUpper(bound{version: v, inclusive: op == "<="})
+		default:
+			r.raiseLower(bound{version: v, inclusive: true})
+			r.lowerUpper(bound{version: v, inclusive: true})
+		}
+	}
+	if r.empty() {
+		return nil, fmt.Errorf("range %q: no version satisfies it", s)
+	}
+	return &r, nil
+}
+
+func splitOperator(comparator string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(comparator, op) {
+			return op, strings.TrimPrefix(comparator, op)
+		}
+	}
+	return "=", comparator
+}
+
+// raiseLower restricts the lower bound of the range to b.
+func (r *versionRange) raiseLower(b bound) {
+	if r.lower == nil || lowerWithin(b, *r.lower) {
+		r.lower = &b
+	}
+}
+
+// lowerUpper restricts the upper bound of the range to b.
+func (r *versionRange) lowerUpper(b bound) {
+	if r.upper == nil || upperWithin(b, *r.upper) {
+		r.upper = &b
+	}
+}
+
+func (r versionRange) empty() bool {
+	if r.lower == nil || r.upper == nil {
+		return false
+	}
+	c := r.lower.version.compare(r.upper.version)
+	return c > 0 || (c == 0 && !(r.lower.inclusive && r.upper.inclusive))
+}
+
+// contains returns true if v is within the range.
+func (r versionRange) contains(v version) bool {
+	if len(v.prerelease) > 0 && !r.prerelease {
+		return false
+	}
+	if r.lower != nil {
+		c := v.compare(r.lower.version)
+		if c < 0 || (c == 0 && !r.lower.inclusive) {
+			return false
+		}
+	}
+	if r.upper != nil {
+		c := v.compare(r.upper.version)
+		if c > 0 || (c == 0 && !r.upper.inclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// within returns true if every version of r is within the parent range.
+func (r versionRange) within(parent versionRange) bool {
+	if r.prerelease && !parent.prerelease {
+		return false
+	}
+	if parent.lower != nil && (r.lower == nil || !lowerWithin(*r.lower, *parent.lower)) {
+		return false
+	}
+	if parent.upper != nil && (r.upper == nil || !upperWithin(*r.upper, *parent.upper)) {
+		return false
+	}
+	return true
+}
+
+// lowerWithin returns true if the lower bound b is at least the lower bound parent.
+func lowerWithin(b, parent bound) bool {
+	c := b.version.compare(parent.version)
+	return c > 0 || (c == 0 && (parent.inclusive || !b.inclusive))
+}
+
+// upperWithin returns true if the upper bound b is at most the upper bound parent.
+func upperWithin(b, parent bound) bool {
+	c := b.version.compare(parent.version)
+	return c < 0 || (c == 0 && (parent.inclusive || !b.inclusive))
+}
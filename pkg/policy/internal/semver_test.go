@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_version_compare(t *testing.T) {
+	t.Parallel()
+
+	// Versions in increasing order of precedence, from the semver spec.
+	ordered := []string{
+		"0.9.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "v1.0.1", "1.2.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := parseVersion(ordered[i])
+			if err != nil {
+				t.Fatalf("%q: %v", ordered[i], err)
+			}
+			b, err := parseVersion(ordered[j])
+			if err != nil {
+				t.Fatalf("%q: %v", ordered[j], err)
+			}
+			if diff := cmp.Diff(sign(i-j), a.compare(b)); diff != "" {
+				t.Errorf("%q and %q: unexpected comparison (-want +got): \n%s", ordered[i], ordered[j], diff)
+			}
+		}
+	}
+}
+
+func Test_parseVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "1.2.3", expected: true},
+		{version: "v1.2.3", expected: true},
+		{version: "1.2.3-rc.1+build.5", expected: true},
+		{version: "1.2", expected: false},
+		{version: "1.2.3.4", expected: false},
+		{version: "01.2.3", expected: false},
+		{version: "1.2.x", expected: false},
+		{version: "1.2.3-", expected: false},
+		{version: "release-1", expected: false},
+	}
+	for _, tt := range tests {
+		_, err := parseVersion(tt.version)
+		if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): %v\n%s", tt.version, err, diff)
+		}
+	}
+}
+
+func Test_versionRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		semver   string
+		versions map[string]bool
+	}{
+		{
+			name:   "major",
+			semver: ">=1.0.0 <2.0.0",
+			versions: map[string]bool{
+				"0.9.0": false, "1.0.0": true, "v1.5.2": true, "2.0.0": false, "2.0.0-rc.1": false, "1.1.0-rc.1": false,
+			},
+		},
+		{
+			name:     "exact",
+			semver:   "1.2.3",
+			versions: map[string]bool{"1.2.3": true, "1.2.4": false},
+		},
+		{
+			name:     "exclusive lower bound",
+			semver:   ">1.2.3",
+			versions: map[string]bool{"1.2.3": false, "1.2.4": true, "10.0.0": true},
+		},
+		{
+			name:     "prerelease comparator",
+			semver:   ">=2.0.0-rc.1 <=2.0.0",
+			versions: map[string]bool{"2.0.0-rc.0": false, "2.0.0-rc.1": true, "2.0.0": true},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := parseVersionRange(tt.semver)
+			if err != nil {
+				t.Fatalf("failed to parse range: %v", err)
+			}
+			for s, expected := range tt.versions {
+				v, err := parseVersion(s)
+				if err != nil {
+					t.Fatalf("%q: %v", s, err)
+				}
+				if diff := cmp.Diff(expected, r.contains(v)); diff != "" {
+					t.Errorf("%q: unexpected result (-want +got): \n%s", s, diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseVersionRange(t *testing.T) {
+	t.Parallel()
+
+	for _, semver := range []string{"", ">=2.0.0 <1.0.0", ">1.0.0 <1.0.0", ">=1.0", "~1.0.0"} {
+		if _, err := parseVersionRange(semver); err == nil {
+			t.Errorf("%q: expected an error", semver)
+		}
+	}
+}
+
+func Test_versionRange_within(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		child, parent string
+		expected      bool
+	}{
+		{child: ">=1.2.0 <2.0.0", parent: ">=1.0.0 <2.0.0", expected: true},
+		{child: "1.5.0", parent: ">=1.0.0 <2.0.0", expected: true},
+		{child: ">=1.0.0 <2.0.0", parent: ">=1.0.0 <2.0.0", expected: true},
+		{child: ">=1.0.0 <=2.0.0", parent: ">=1.0.0 <2.0.0", expected: false},
+		{child: ">=0.9.0 <2.0.0", parent: ">=1.0.0 <2.0.0", expected: false},
+		{child: ">=1.0.0", parent: ">=1.0.0 <2.0.0", expected: false},
+		{child: ">=1.0.0 <2.0.0", parent: ">=1.0.0", expected: true},
+		{child: ">=1.0.0-rc.1 <2.0.0", parent: ">=0.1.0", expected: false},
+	}
+	for _, tt := range tests {
+		child, err := parseVersionRange(tt.child)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.child, err)
+		}
+		parent, err := parseVersionRange(tt.parent)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.parent, err)
+		}
+		if diff := cmp.Diff(tt.expected, child.within(*parent)); diff != "" {
+			t.Errorf("%q within %q: unexpected result (-want +got): \n%s", tt.child, tt.parent, diff)
+		}
+	}
+}
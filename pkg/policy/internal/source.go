@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/sourceuri"
+)
+
+// normalizeSources canonicalizes the source patterns, so that they
+// match the canonical sources being evaluated.
+func normalizeSources(sources []Resource) {
+	for i := range sources {
+		sources[i].URI.Glob = sourceuri.NormalizePattern(sources[i].URI.Glob)
+	}
+}
+
+// globSource matches a source URI against a pattern. The repository and
+// the ref are matched separately: a pattern without a ref matches every
+// ref of the repository.
+func globSource(syn syntax, pattern, uri string) bool {
+	if strings.Trim(pattern, GLOB) == "" {
+		return syn.match(pattern, uri)
+	}
+	p := sourceuri.ParsePattern(pattern)
+	u := sourceuri.ParsePattern(uri)
+	if !syn.match(p.Repository(), u.Repository()) {
+		return false
+	}
+	return p.Ref == "" || syn.match(p.Ref, u.Ref)
+}
+
+// matchSource matches the source of the input against a pattern.
+// Globs are matched with globSource.
+func matchSource(syn syntax, pattern Pattern, in *input) bool {
+	return pattern.match(func(pattern, uri string) bool {
+		return globSource(syn, pattern, uri)
+	}, in.source, in)
+}
+
+// coversSource returns true if every source matched by the child
+// pattern is also matched by the parent pattern. A child without
+// a ref is only covered by a parent without a ref.
+func coversSource(cov coverage, parent, child string) bool {
+	if strings.Trim(parent, GLOB) == "" {
+		return cov.covers(parent, child)
+	}
+	p := sourceuri.ParsePattern(parent)
+	c := sourceuri.ParsePattern(child)
+	if !cov.covers(p.Repository(), c.Repository()) {
+		return false
+	}
+	return p.Ref == "" || (c.Ref != "" && cov.covers(p.Ref, c.Ref))
+}
+
+func sourceCovered(c coverage, parents []Resource, uri Pattern) bool {
+	if len(parents) == 0 {
+		return true
+	}
+	for i := range parents {
+		if coversPattern(c, parents[i].URI, uri, coversSource) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package options
+
+import "github.com/laurentsimon/slsa-e2e/pkg/intoto"
+
+// Evaluation contains the optional inputs of an evaluation.
+type Evaluation struct {
+	// SourceAttestorID is the identity of the verifier of
+	// the source attestation.
+	SourceAttestorID string
+	// SourceLevel is the attested source level, if any.
+	SourceLevel *int
+	// Labels are the labels of the evaluation, e.g. the namespace
+	// of the deployment. Entries may select them.
+	Labels map[string]string
+	// Environment is the environment the image is evaluated for,
+	// e.g. prod. CEL predicates may refer to it.
+	Environment string
+	// Subjects are the subjects of the attestation the inputs
+	// come from, if any. The image must then be pinned by a digest
+	// that appears in the subjects.
+	Subjects []intoto.ResourceDescriptor
+}
+
+// Option configures an evaluation.
+type Option func(*Evaluation)
+
+// WithSourceAttestation sets the identity of the source attestation verifier.
+func WithSourceAttestation(attestorID string) Option {
+	return func(e *Evaluation) {
+		e.SourceAttestorID = attestorID
+	}
+}
+
+// WithSourceLevel sets the attested source level.
+func WithSourceLevel(level int) Option {
+	return func(e *Evaluation) {
+		e.SourceLevel = &level
+	}
+}
+
+// WithLabels sets the labels of the evaluation.
+func WithLabels(labels map[string]string) Option {
+	return func(e *Evaluation) {
+		e.Labels = labels
+	}
+}
+
+// WithEnvironment sets the environment of the evaluation.
+func WithEnvironment(environment string) Option {
+	return func(e *Evaluation) {
+		e.Environment = environment
+	}
+}
+
+// WithSubjects sets the subjects of the attestation the inputs come from.
+func WithSubjects(subjects []intoto.ResourceDescriptor) Option {
+	return func(e *Evaluation) {
+		e.Subjects = subjects
+	}
+}
+
+// New returns the evaluation configured by opts.
+func New(opts ...Option) Evaluation {
+	var e Evaluation
+	for _, opt := range opts {
+		opt(&e)
+	}
+	return e
+}
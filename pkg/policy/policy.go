@@ -2,15 +2,21 @@ package policy
 
 import (
 	internal "github.com/laurentsimon/slsa-e2e/pkg/policy/internal"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/options"
 	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
 )
 
+// ErrNoTrustRoots is returned when verifying an attestation
+// with a policy that declares no trust roots.
+var ErrNoTrustRoots = internal.ErrNoTrustRoots
+
 // Policy defineds a policy.
 type Policy struct {
 	policy *internal.Policy
 }
 
-// Build a policy fr an ordered list of files.
+// FromFiles builds a policy from an ordered list of files,
+// starting with the org policy.
 func FromFiles(files []string) (*Policy, error) {
 	policy, err := internal.FromFiles(files)
 	if err != nil {
@@ -21,7 +27,14 @@ func FromFiles(files []string) (*Policy, error) {
 	}, nil
 }
 
+// VerifyAttestation verifies a DSSE envelope or a Sigstore bundle of a
+// provenance with the trust roots of the org policy and returns its payload.
+// The trust root must allow the builder of the provenance.
+func (p *Policy) VerifyAttestation(content []byte) ([]byte, error) {
+	return p.policy.VerifyAttestation(content)
+}
+
 // Evaluate evaluates the policy.
-func (p *Policy) Evaluate(sourceURI, imageURI, builderID string) results.Verification {
-	return p.policy.Evaluate(sourceURI, imageURI, builderID)
+func (p *Policy) Evaluate(sourceURI, imageURI, builderID string, opts ...options.Option) results.Verification {
+	return p.policy.Evaluate(sourceURI, imageURI, builderID, opts...)
 }
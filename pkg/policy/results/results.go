@@ -1,13 +1,62 @@
 package results
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
 
 type verificationStatus string
 
 type Verification struct {
-	status  verificationStatus
-	message string
-	err     error
+	status     verificationStatus
+	message    string
+	reasons    []error
+	err        error
+	rules      []Rule
+	matches    []Match
+	buildLevel int
+}
+
+// Rule is a pattern or requirement checked during an evaluation.
+type Rule struct {
+	// Policy is the file the rule comes from, if known.
+	Policy string `json:"policy,omitempty"`
+	// Level is the index of the policy in the hierarchy, starting with the org at 0.
+	Level int `json:"level"`
+	// Type is the type of the policy, e.g. "org" or "repo".
+	Type string `json:"type"`
+	// Entry is the path of the entry within the policy, e.g. "defaults" or "projects[0]".
+	Entry string `json:"entry"`
+	// Path is the path of the rule within the policy, e.g. "defaults.images[0].uri".
+	Path string `json:"path"`
+	// Pattern is the pattern or requirement of the rule.
+	Pattern string `json:"pattern"`
+	// Value is the evaluated value.
+	Value string `json:"value"`
+	// Match is true if the value satisfies the rule.
+	Match bool `json:"match"`
+}
+
+// Match is an entry satisfied during an evaluation.
+type Match struct {
+	Policy string `json:"policy,omitempty"`
+	Level  int    `json:"level"`
+	Type   string `json:"type"`
+	Entry  string `json:"entry"`
+}
+
+// Result is the machine-readable form of a verification.
+type Result struct {
+	// Decision is one of "pass", "fail", "audit" or "invalid".
+	Decision string   `json:"decision"`
+	Message  string   `json:"message,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Matches  []Match  `json:"matches,omitempty"`
+	Rules    []Rule   `json:"rules,omitempty"`
+	// BuildLevel is the SLSA build level of the builder, as given by the policy.
+	BuildLevel int `json:"build_level,omitempty"`
 }
 
 const (
@@ -37,10 +86,11 @@ func VerificationPass() Verification {
 	}
 }
 
-func VerificationAudit(message string) Verification {
+func VerificationAudit(message string, reasons ...error) Verification {
 	return Verification{
 		status:  verificationStatusAudit,
 		message: message,
+		reasons: reasons,
 	}
 }
 
@@ -60,6 +110,77 @@ func (v Verification) Invalid() bool {
 	return v.status == verificationStatusInvalid
 }
 
+// WithRules returns a copy of the verification with the rules
+// checked and the entries matched during the evaluation.
+func (v Verification) WithRules(rules []Rule, matches []Match) Verification {
+	v.rules = rules
+	v.matches = matches
+	return v
+}
+
+// WithBuildLevel returns a copy of the verification with
+// the build level of the builder.
+func (v Verification) WithBuildLevel(level int) Verification {
+	v.buildLevel = level
+	return v
+}
+
+// BuildLevel returns the build level of the builder.
+func (v Verification) BuildLevel() int {
+	return v.buildLevel
+}
+
+// Rules returns the rules checked during the evaluation.
+func (v Verification) Rules() []Rule {
+	return v.rules
+}
+
+// Matches returns the entries matched during the evaluation.
+func (v Verification) Matches() []Match {
+	return v.matches
+}
+
+// Result returns the machine-readable form of the verification.
+func (v Verification) Result() Result {
+	result := Result{
+		Decision:   string(v.status),
+		Message:    v.message,
+		Matches:    v.matches,
+		Rules:      v.rules,
+		BuildLevel: v.buildLevel,
+	}
+	reasons := v.reasons
+	if v.err != nil {
+		if joined, ok := v.err.(interface{ Unwrap() []error }); ok {
+			reasons = joined.Unwrap()
+		} else {
+			reasons = []error{v.err}
+		}
+	}
+	for _, reason := range reasons {
+		result.Reasons = append(result.Reasons, reason.Error())
+	}
+	return result
+}
+
+// MarshalJSON marshals the machine-readable form of the verification.
+func (v Verification) MarshalJSON() ([]byte, error) {
+	if v.status == "" {
+		return nil, errors.New("empty verification")
+	}
+	return json.Marshal(v.Result())
+}
+
+// Reasons returns the reasons of an audit verification.
+func (v Verification) Reasons() []error {
+	return v.reasons
+}
+
+// Err returns the error of a failed or invalid verification.
+func (v Verification) Err() error {
+	return v.err
+}
+
 func (v Verification) String() string {
 	switch v.status {
 	case verificationStatusPass:
@@ -67,7 +188,12 @@ func (v Verification) String() string {
 	case verificationStatusFail:
 		return fmt.Sprintf("FAIL: %v", v.err)
 	case verificationStatusAudit:
-		return fmt.Sprintf("AUDIT: %v", v.message)
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "AUDIT: %v", v.message)
+		for _, reason := range v.reasons {
+			fmt.Fprintf(&sb, "\n  - %v", reason)
+		}
+		return sb.String()
 	case verificationStatusInvalid:
 		return fmt.Sprintf("INVALID: %v", v.err)
 	default:
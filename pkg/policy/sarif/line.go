@@ -0,0 +1,102 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// segment is a field or an index of a path.
+type segment struct {
+	key string
+	// index is -1 for a field.
+	index int
+}
+
+// Line returns the line of path, e.g. "projects[0].images[1].uri", in the
+// JSON content. If path is not found, the line of its deepest found
+// parent is returned. It returns 0 if no part of path is found.
+func Line(content []byte, path string) int {
+	line, _ := find(content, path)
+	return line
+}
+
+// find returns the line of path in the JSON content and whether
+// the whole path was found.
+func find(content []byte, path string) (int, bool) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	segments := parsePath(path)
+	var offset int64
+	found := walk(dec, segments, &offset)
+	if offset == 0 {
+		return 0, false
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1, found
+}
+
+func parsePath(path string) []segment {
+	var segments []segment
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			segments = append(segments, segment{key: key, index: -1})
+		}
+		for rest != "" {
+			var index string
+			index, rest, _ = strings.Cut(rest, "]")
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				return segments
+			}
+			segments = append(segments, segment{index: i})
+			rest = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return segments
+}
+
+// walk follows the segments in the value read next by dec and records the
+// offset of the deepest segment found. It returns true if every segment is found.
+func walk(dec *json.Decoder, segments []segment, offset *int64) bool {
+	if len(segments) == 0 {
+		return true
+	}
+	token, err := dec.Token()
+	if err != nil {
+		return false
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return false
+	}
+	seg := segments[0]
+	switch {
+	case delim == '{' && seg.index < 0:
+		for dec.More() {
+			token, err := dec.Token()
+			if err != nil {
+				return false
+			}
+			if key, ok := token.(string); ok && key == seg.key {
+				*offset = dec.InputOffset()
+				return walk(dec, segments[1:], offset)
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return false
+			}
+		}
+	case delim == '[' && seg.index >= 0:
+		for i := 0; dec.More(); i++ {
+			if i == seg.index {
+				return walk(dec, segments[1:], offset)
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return false
+			}
+		}
+	}
+	return false
+}
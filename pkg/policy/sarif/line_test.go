@@ -0,0 +1,85 @@
+package sarif
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testPolicy = `{
+    "version": 1,
+    "defaults": {
+        "images": [
+            {
+                "uri": "docker://org/*"
+            }
+        ]
+    },
+    "projects": [
+        {
+            "sources": [{"uri": "git+https://github.com/org/a"}]
+        },
+        {
+            "sources": [
+                {"uri": "git+https://github.com/org/b"},
+                {"uri": "git+https://github.com/org/c"}
+            ]
+        }
+    ]
+}`
+
+func Test_Line(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{
+			name:     "field",
+			path:     "version",
+			expected: 2,
+		},
+		{
+			name:     "nested field",
+			path:     "defaults.images[0].uri",
+			expected: 6,
+		},
+		{
+			name:     "second element",
+			path:     "projects[1].sources[1].uri",
+			expected: 17,
+		},
+		{
+			name:     "inline element",
+			path:     "projects[0].sources[0].uri",
+			expected: 12,
+		},
+		{
+			name:     "missing field",
+			path:     "projects[0].images[0].uri",
+			expected: 10,
+		},
+		{
+			name:     "missing index",
+			path:     "projects[2].sources[0].uri",
+			expected: 10,
+		},
+		{
+			name:     "not found",
+			path:     "enforcement",
+			expected: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tt.expected, Line([]byte(testPolicy), tt.path)); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Package sarif converts evaluation results to the SARIF format.
+package sarif
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+const (
+	version        = "2.1.0"
+	schema         = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolName       = "slsa-e2e"
+	informationURI = "https://github.com/laurentsimon/slsa-e2e"
+	// ruleInvalid is the rule of an invalid evaluation.
+	ruleInvalid = "invalid"
+	// ruleViolation is the rule of a violation that no policy rule explains.
+	ruleViolation = "violation"
+)
+
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []Run  `json:"runs"`
+}
+
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+type Rule struct {
+	ID               string  `json:"id"`
+	ShortDescription Message `json:"shortDescription"`
+}
+
+type Message struct {
+	Text string `json:"text"`
+}
+
+type Result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+}
+
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type Region struct {
+	StartLine int `json:"startLine"`
+}
+
+// indexes matches the array indexes of a rule path.
+var indexes = regexp.MustCompile(`\[[0-9]+\]`)
+
+// FromResult converts a result to a SARIF log. At each level of the
+// hierarchy without a matching entry, the rules that are not satisfied
+// are reported at their location in the policy file. If some entries
+// of the level apply to the source, only their rules are reported.
+// Policy files are read to locate the rules.
+func FromResult(result results.Result) (*Log, error) {
+	run := Run{
+		Tool: Tool{
+			Driver: Driver{
+				Name:           toolName,
+				InformationURI: informationURI,
+			},
+		},
+		Results: []Result{},
+	}
+
+	switch result.Decision {
+	case "pass":
+	case "invalid":
+		for _, reason := range result.Reasons {
+			run.add(Result{
+				RuleID:  ruleInvalid,
+				Level:   "error",
+				Message: Message{Text: reason},
+			}, "The policy or the evaluation inputs are invalid")
+		}
+	case "fail", "audit":
+		level := "error"
+		if result.Decision == "audit" {
+			level = "warning"
+		}
+		if err := run.addViolations(result, level); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid decision: %q", result.Decision)
+	}
+
+	return &Log{
+		Version: version,
+		Schema:  schema,
+		Runs:    []Run{run},
+	}, nil
+}
+
+func (r *Run) addViolations(result results.Result, level string) error {
+	matched := make(map[int]bool, len(result.Matches))
+	for i := range result.Matches {
+		matched[result.Matches[i].Level] = true
+	}
+	// An entry applies if one of its rules is satisfied,
+	// which is the case of its sources.
+	type entry struct {
+		level int
+		name  string
+	}
+	applied := make(map[entry]bool)
+	levelApplied := make(map[int]bool)
+	for i := range result.Rules {
+		rule := &result.Rules[i]
+		if rule.Match {
+			applied[entry{rule.Level, rule.Entry}] = true
+			levelApplied[rule.Level] = true
+		}
+	}
+	contents := make(map[string][]byte)
+	for i := range result.Rules {
+		rule := &result.Rules[i]
+		if rule.Match || matched[rule.Level] {
+			continue
+		}
+		if levelApplied[rule.Level] && !applied[entry{rule.Level, rule.Entry}] {
+			continue
+		}
+		location, err := locate(contents, *rule)
+		if err != nil {
+			return err
+		}
+		id := ruleID(*rule)
+		res := Result{
+			RuleID: id,
+			Level:  level,
+			Message: Message{
+				Text: fmt.Sprintf("%q policy: %s: %q does not satisfy %q", rule.Type, rule.Path, rule.Value, rule.Pattern),
+			},
+		}
+		if location != nil {
+			res.Locations = []Location{*location}
+		}
+		r.add(res, fmt.Sprintf("Value must satisfy %s of the %s policy", strings.TrimPrefix(id, rule.Type+"/"), rule.Type))
+	}
+	// Report the violations if no rule explains them.
+	if len(r.Results) == 0 {
+		for _, reason := range result.Reasons {
+			r.add(Result{
+				RuleID:  ruleViolation,
+				Level:   level,
+				Message: Message{Text: reason},
+			}, "The policy is violated")
+		}
+	}
+	return nil
+}
+
+// add adds a result and declares its rule.
+func (r *Run) add(result Result, description string) {
+	r.Results = append(r.Results, result)
+	for i := range r.Tool.Driver.Rules {
+		if r.Tool.Driver.Rules[i].ID == result.RuleID {
+			return
+		}
+	}
+	r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, Rule{
+		ID:               result.RuleID,
+		ShortDescription: Message{Text: description},
+	})
+}
+
+// ruleID returns the ID of a rule, which is its path without
+// the entry and indexes, e.g. "org/images.uri". The path of a rule
+// that a project inherits starts with the defaults instead of the project.
+func ruleID(rule results.Rule) string {
+	_, path, _ := strings.Cut(rule.Path, ".")
+	return rule.Type + "/" + indexes.ReplaceAllString(path, "")
+}
+
+// locate returns the location of a rule in its policy file, or nil if
+// the file is unknown. Contents are cached by file.
+// Rules that a project inherits from the defaults, e.g. its images,
+// are located in the defaults. Inherited builders and source attestors
+// already have their path in the defaults.
+func locate(contents map[string][]byte, rule results.Rule) (*Location, error) {
+	file := rule.Policy
+	if file == "" {
+		return nil, nil
+	}
+	content, ok := contents[file]
+	if !ok {
+		var err error
+		content, err = os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		contents[file] = content
+	}
+	location := Location{
+		PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: file},
+		},
+	}
+	line, found := find(content, rule.Path)
+	if !found && rule.Entry != "defaults" && strings.HasPrefix(rule.Entry, "projects[") {
+		if defaultsLine, ok := find(content, "defaults"+strings.TrimPrefix(rule.Path, rule.Entry)); ok {
+			line = defaultsLine
+		}
+	}
+	if line > 0 {
+		location.PhysicalLocation.Region = &Region{StartLine: line}
+	}
+	return &location, nil
+}
@@ -0,0 +1,183 @@
+package sarif
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_FromResult(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "org.json")
+	if err := os.WriteFile(file, []byte(testPolicy), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	rule := func(entry, path, pattern, value string, match bool) results.Rule {
+		return results.Rule{
+			Policy: file, Type: "org", Entry: entry, Path: entry + "." + path,
+			Pattern: pattern, Value: value, Match: match,
+		}
+	}
+	location := func(line int) []Location {
+		return []Location{{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: file},
+			Region:           &Region{StartLine: line},
+		}}}
+	}
+
+	tests := []struct {
+		name     string
+		result   results.Result
+		expected []Result
+		err      bool
+	}{
+		{
+			name:     "pass",
+			result:   results.Result{Decision: "pass"},
+			expected: []Result{},
+		},
+		{
+			name: "fail on the applied entry",
+			result: results.Result{
+				Decision: "fail",
+				Reasons:  []string{"image uri mismatch"},
+				Rules: []results.Rule{
+					rule("projects[0]", "sources[0].uri", "git+https://github.com/org/a", "git+https://github.com/org/a", true),
+					rule("projects[0]", "images[0].uri", "docker://org/*", "docker://other/image", false),
+					rule("projects[1]", "sources[0].uri", "git+https://github.com/org/b", "git+https://github.com/org/a", false),
+				},
+			},
+			expected: []Result{
+				{
+					RuleID:    "org/images.uri",
+					Level:     "error",
+					Message:   Message{Text: `"org" policy: projects[0].images[0].uri: "docker://other/image" does not satisfy "docker://org/*"`},
+					Locations: location(6),
+				},
+			},
+		},
+		{
+			name: "audit on source mismatch",
+			result: results.Result{
+				Decision: "audit",
+				Reasons:  []string{"source uri mismatch"},
+				Rules: []results.Rule{
+					rule("projects[1]", "sources[1].uri", "git+https://github.com/org/c", "git+https://github.com/other/repo", false),
+				},
+			},
+			expected: []Result{
+				{
+					RuleID:    "org/sources.uri",
+					Level:     "warning",
+					Message:   Message{Text: `"org" policy: projects[1].sources[1].uri: "git+https://github.com/other/repo" does not satisfy "git+https://github.com/org/c"`},
+					Locations: location(17),
+				},
+			},
+		},
+		{
+			name: "invalid",
+			result: results.Result{
+				Decision: "invalid",
+				Reasons:  []string{"failed to create policy"},
+			},
+			expected: []Result{
+				{RuleID: "invalid", Level: "error", Message: Message{Text: "failed to create policy"}},
+			},
+		},
+		{
+			name:   "invalid decision",
+			result: results.Result{Decision: "unknown"},
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			log, err := FromResult(tt.result)
+			if diff := cmp.Diff(tt.err, err != nil); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, log.Runs[0].Results); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+const testExtendPolicy = `{
+    "version": 1,
+    "defaults": {
+        "sources": [{"uri": "git+https://github.com/org/*"}],
+        "tracks": {"build": {"builders": [
+            {"id": "https://github.com/org/default-builder", "level": 3}
+        ]}}
+    },
+    "projects": [
+        {
+            "sources": [{"uri": "git+https://github.com/org/repo"}],
+            "tracks": {"build": {"merge": "extend", "builders": [
+                {"id": "https://github.com/org/project-builder", "level": 3}
+            ]}}
+        }
+    ]
+}`
+
+// Test_FromResult_extend locates the builders of a project that
+// extends the builders of the defaults.
+func Test_FromResult_extend(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	org := filepath.Join(dir, "org.json")
+	repo := filepath.Join(dir, "repo.json")
+	if err := os.WriteFile(org, []byte(testExtendPolicy), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(repo, []byte(`{"version": 1}`), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	pol, err := policy.FromFiles([]string{org, repo})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	result := pol.Evaluate("git+https://github.com/org/repo", "docker.io/org/image", "https://github.com/org/other-builder")
+	log, err := FromResult(result.Result())
+	if err != nil {
+		t.Fatalf("failed to convert: %v", err)
+	}
+
+	type location struct {
+		message string
+		line    int
+	}
+	var got []location
+	for _, res := range log.Runs[0].Results {
+		l := location{message: res.Message.Text}
+		if len(res.Locations) > 0 && res.Locations[0].PhysicalLocation.Region != nil {
+			l.line = res.Locations[0].PhysicalLocation.Region.StartLine
+		}
+		got = append(got, l)
+	}
+	message := func(path, pattern string) string {
+		return `"org" policy: ` + path + `: "https://github.com/org/other-builder" does not satisfy "` + pattern + `"`
+	}
+	expected := []location{
+		{message: message("defaults.tracks.build.builders[0].id", "https://github.com/org/default-builder"), line: 6},
+		{message: message("defaults.tracks.build.builders[0].id", "https://github.com/org/default-builder"), line: 6},
+		{message: message("projects[0].tracks.build.builders[0].id", "https://github.com/org/project-builder"), line: 13},
+	}
+	if diff := cmp.Diff(expected, got, cmp.AllowUnexported(location{})); diff != "" {
+		t.Fatalf("unexpected locations (-want +got): \n%s", diff)
+	}
+}
@@ -0,0 +1,216 @@
+// Package provenance reads SLSA provenance.
+package provenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+)
+
+// Predicate types of the supported SLSA provenance versions.
+const (
+	PredicateTypeV02 = "https://slsa.dev/provenance/v0.2"
+	PredicateTypeV1  = "https://slsa.dev/provenance/v1"
+)
+
+// Provenance contains the fields of a SLSA provenance used for
+// policy evaluation.
+type Provenance struct {
+	PredicateType string
+	BuilderID     string
+	// SourceURI is the URI of the source repository without its ref,
+	// e.g. git+https://github.com/org/repo.
+	SourceURI string
+	// Ref is the source ref, e.g. refs/heads/main, if known.
+	Ref string
+	// Commit is the source commit, if known.
+	Commit   string
+	Subjects []intoto.ResourceDescriptor
+}
+
+type statement = intoto.Statement[json.RawMessage]
+
+// predicateV02 contains the fields used of the v0.2 predicate.
+type predicateV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	Invocation struct {
+		ConfigSource material `json:"configSource"`
+	} `json:"invocation"`
+	Materials []material `json:"materials"`
+}
+
+type material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+// predicateV1 contains the fields used of the v1 predicate.
+type predicateV1 struct {
+	BuildDefinition struct {
+		ExternalParameters struct {
+			Workflow struct {
+				Ref        string `json:"ref"`
+				Repository string `json:"repository"`
+			} `json:"workflow"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []material `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+	} `json:"runDetails"`
+}
+
+// FromFile reads a provenance from a file.
+func FromFile(path string) (*Provenance, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return FromBytes(content)
+}
+
+// FromBytes reads a provenance from an in-toto statement, or from
+// a DSSE envelope or a Sigstore bundle that contains it.
+// Signatures are not verified.
+func FromBytes(content []byte) (*Provenance, error) {
+	payload, err := statementBytes(content)
+	if err != nil {
+		return nil, err
+	}
+	var st statement
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if len(st.Subject) == 0 {
+		return nil, fmt.Errorf("empty %q", "subject")
+	}
+
+	var p *Provenance
+	switch st.PredicateType {
+	case PredicateTypeV02:
+		p, err = fromV02(st.Predicate)
+	case PredicateTypeV1:
+		p, err = fromV1(st.Predicate)
+	default:
+		return nil, fmt.Errorf("unsupported predicate type: %q", st.PredicateType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.PredicateType = st.PredicateType
+	p.Subjects = st.Subject
+	return p, nil
+}
+
+// statementBytes returns the statement of content, decoding
+// the payload of DSSE envelopes and Sigstore bundles.
+func statementBytes(content []byte) ([]byte, error) {
+	var fields struct {
+		Payload      string          `json:"payload"`
+		DsseEnvelope json.RawMessage `json:"dsseEnvelope"`
+	}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if fields.Payload == "" && fields.DsseEnvelope == nil {
+		return content, nil
+	}
+	envelope, _, err := dsse.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	if envelope.PayloadType != dsse.PayloadType {
+		return nil, fmt.Errorf("invalid payload type: %q", envelope.PayloadType)
+	}
+	return envelope.DecodePayload()
+}
+
+func fromV02(content json.RawMessage) (*Provenance, error) {
+	var predicate predicateV02
+	if err := json.Unmarshal(content, &predicate); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if predicate.Builder.ID == "" {
+		return nil, fmt.Errorf("empty %q", "builder.id")
+	}
+	// The config source is set by GitHub builders. Other builders,
+	// like Cloud Build, only list the source in the materials.
+	source := predicate.Invocation.ConfigSource
+	if source.URI == "" {
+		source = gitMaterial(predicate.Materials)
+	}
+	if source.URI == "" {
+		return nil, errors.New("no source")
+	}
+	p := Provenance{BuilderID: predicate.Builder.ID}
+	p.SourceURI, p.Ref = splitRef(source.URI)
+	p.Commit = commit(source.Digest)
+	return &p, nil
+}
+
+func fromV1(content json.RawMessage) (*Provenance, error) {
+	var predicate predicateV1
+	if err := json.Unmarshal(content, &predicate); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if predicate.RunDetails.Builder.ID == "" {
+		return nil, fmt.Errorf("empty %q", "runDetails.builder.id")
+	}
+	p := Provenance{BuilderID: predicate.RunDetails.Builder.ID}
+	source := gitMaterial(predicate.BuildDefinition.ResolvedDependencies)
+	if source.URI != "" {
+		p.SourceURI, p.Ref = splitRef(source.URI)
+		p.Commit = commit(source.Digest)
+	}
+	// GitHub Actions workflows declare the repository and ref
+	// in their external parameters.
+	workflow := predicate.BuildDefinition.ExternalParameters.Workflow
+	if workflow.Repository != "" {
+		p.SourceURI = "git+" + workflow.Repository
+		p.Ref = workflow.Ref
+	}
+	if p.SourceURI == "" {
+		return nil, errors.New("no source")
+	}
+	return &p, nil
+}
+
+// gitMaterial returns the first git material.
+func gitMaterial(materials []material) material {
+	for i := range materials {
+		if strings.HasPrefix(materials[i].URI, "git+") {
+			return materials[i]
+		}
+	}
+	return material{}
+}
+
+// splitRef splits a source URI of the form uri@ref.
+func splitRef(uri string) (string, string) {
+	i := strings.LastIndex(uri, "@")
+	if i < 0 {
+		return uri, ""
+	}
+	ref := uri[i+1:]
+	// An @ followed by a path is part of the authority, e.g. git@github.com/org/repo.
+	if strings.Contains(ref, "/") && !strings.HasPrefix(ref, "refs/") {
+		return uri, ""
+	}
+	return uri[:i], ref
+}
+
+func commit(digest map[string]string) string {
+	if c, ok := digest["gitCommit"]; ok {
+		return c
+	}
+	return digest["sha1"]
+}
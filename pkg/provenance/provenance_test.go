@@ -0,0 +1,151 @@
+package provenance
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+)
+
+func Test_FromBytes(t *testing.T) {
+	t.Parallel()
+
+	githubSubjects := []intoto.ResourceDescriptor{
+		{
+			Name:   "docker.io/org/image",
+			Digest: map[string]string{"sha256": "2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a"},
+		},
+	}
+	githubV02 := &Provenance{
+		PredicateType: PredicateTypeV02,
+		BuilderID:     "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml@refs/tags/v1.7.0",
+		SourceURI:     "git+https://github.com/org/repo",
+		Ref:           "refs/heads/main",
+		Commit:        "8f0e1b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a",
+		Subjects:      githubSubjects,
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		expected *Provenance
+	}{
+		{
+			name:     "github v0.2",
+			file:     "github-v0.2.json",
+			expected: githubV02,
+		},
+		{
+			name:     "github v0.2 envelope",
+			file:     "github-v0.2.dsse.json",
+			expected: githubV02,
+		},
+		{
+			name: "cloud build v0.2",
+			file: "gcb-v0.2.json",
+			expected: &Provenance{
+				PredicateType: PredicateTypeV02,
+				BuilderID:     "https://cloudbuild.googleapis.com/GoogleHostedWorker",
+				SourceURI:     "git+https://github.com/org/repo",
+				Commit:        "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b",
+				Subjects: []intoto.ResourceDescriptor{
+					{
+						Name:   "us-west2-docker.pkg.dev/project/repo/image",
+						Digest: map[string]string{"sha256": "4e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f"},
+					},
+				},
+			},
+		},
+		{
+			name: "github v1",
+			file: "github-v1.json",
+			expected: &Provenance{
+				PredicateType: PredicateTypeV1,
+				BuilderID:     "https://github.com/org/.github/.github/workflows/builder.yml@refs/heads/main",
+				SourceURI:     "git+https://github.com/org/repo",
+				Ref:           "refs/tags/v1.2.3",
+				Commit:        "8f0e1b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a",
+				Subjects:      githubSubjects,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := FromFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("failed to read provenance: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, p); diff != "" {
+				t.Fatalf("unexpected result (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_FromBytes_invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "not JSON",
+			content: `provenance`,
+		},
+		{
+			name:    "unsupported predicate",
+			content: `{"subject": [{"name": "image"}], "predicateType": "https://slsa.dev/provenance/v0.1", "predicate": {}}`,
+		},
+		{
+			name:    "no subject",
+			content: `{"predicateType": "https://slsa.dev/provenance/v1", "predicate": {"runDetails": {"builder": {"id": "builder"}}}}`,
+		},
+		{
+			name:    "no builder",
+			content: `{"subject": [{"name": "image"}], "predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {"materials": [{"uri": "git+https://github.com/org/repo"}]}}`,
+		},
+		{
+			name:    "no source",
+			content: `{"subject": [{"name": "image"}], "predicateType": "https://slsa.dev/provenance/v1", "predicate": {"runDetails": {"builder": {"id": "builder"}}}}`,
+		},
+		{
+			name:    "invalid payload type",
+			content: `{"payloadType": "text/plain", "payload": "e30="}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := FromBytes([]byte(tt.content)); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
+
+func Test_splitRef(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		uri, source, ref string
+	}{
+		{uri: "git+https://github.com/org/repo@refs/heads/main", source: "git+https://github.com/org/repo", ref: "refs/heads/main"},
+		{uri: "git+https://github.com/org/repo@v1.2.3", source: "git+https://github.com/org/repo", ref: "v1.2.3"},
+		{uri: "git+https://github.com/org/repo", source: "git+https://github.com/org/repo"},
+		{uri: "git+ssh://git@github.com/org/repo", source: "git+ssh://git@github.com/org/repo"},
+	}
+	for _, tt := range tests {
+		source, ref := splitRef(tt.uri)
+		if diff := cmp.Diff([]string{tt.source, tt.ref}, []string{source, ref}); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): \n%s", tt.uri, diff)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package attestation defines the release attestation, created
+// when a package passes its release policy.
+package attestation
+
+import "github.com/laurentsimon/slsa-e2e/pkg/intoto"
+
+// PredicateType is the type of the release predicate.
+const PredicateType = "https://slsa.dev/release/v0.1"
+
+type Package struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment,omitempty"`
+}
+
+type Releaser struct {
+	ID string `json:"id"`
+}
+
+type Repository struct {
+	URI string `json:"uri"`
+}
+
+type Builder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Build struct {
+	Repository Repository `json:"repository"`
+	// Builder is the builder the package policy requires.
+	Builder   Builder `json:"builder"`
+	SlsaLevel int     `json:"slsa_level"`
+}
+
+// Predicate is the release predicate.
+type Predicate struct {
+	CreationTime string   `json:"creationTime"`
+	Package      Package  `json:"package"`
+	Releaser     Releaser `json:"releaser"`
+	Build        Build    `json:"build"`
+	// Policy contains the policy files the package was verified against.
+	Policy []intoto.ResourceDescriptor `json:"policy"`
+}
+
+type Statement = intoto.Statement[Predicate]
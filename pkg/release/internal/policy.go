@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/imageref"
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/attestation"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/options"
+	"github.com/laurentsimon/slsa-e2e/pkg/sourceuri"
+)
+
+type Root struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SlsaLevel int    `json:"slsa_level"`
+}
+
+type Roots struct {
+	Build []Root `json:"build"`
+}
+
+type OrgPolicy struct {
+	Format int   `json:"format"`
+	Roots  Roots `json:"roots"`
+}
+
+type Environment struct {
+	AnyOf []string `json:"any_of"`
+}
+
+type Package struct {
+	Name        string       `json:"name"`
+	Environment *Environment `json:"environment"`
+}
+
+type Repository struct {
+	URI string `json:"uri"`
+}
+
+type Build struct {
+	RequireSlsaBuilder string     `json:"require_slsa_builder"`
+	Repository         Repository `json:"repository"`
+}
+
+type ProjectPolicy struct {
+	Format  int     `json:"format"`
+	Package Package `json:"package"`
+	Build   Build   `json:"build"`
+}
+
+type context string
+
+const (
+	contextOrg     context = "org"
+	contextProject context = "project"
+)
+
+type Policy struct {
+	orgPolicy OrgPolicy
+	// Project policies indexed by package name.
+	projectPolicies map[string]ProjectPolicy
+	// Descriptors of the policy files, recorded in release attestations.
+	orgFile      intoto.ResourceDescriptor
+	projectFiles map[string]intoto.ResourceDescriptor
+}
+
+// file is a policy file. Its path is empty if the
+// policy was not read from a file.
+type file struct {
+	path    string
+	content []byte
+}
+
+func FromBytes(org []byte, projects [][]byte) (*Policy, error) {
+	files := make([]file, len(projects))
+	for i := range projects {
+		files[i].content = projects[i]
+	}
+	return fromFiles(file{content: org}, files)
+}
+
+func fromFiles(org file, projects []file) (*Policy, error) {
+	var orgPolicy OrgPolicy
+	if err := json.Unmarshal(org.content, &orgPolicy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	if err := validateOrgPolicy(orgPolicy); err != nil {
+		return nil, err
+	}
+
+	projectPolicies := make(map[string]ProjectPolicy, len(projects))
+	projectFiles := make(map[string]intoto.ResourceDescriptor, len(projects))
+	for i := range projects {
+		pfile := &projects[i]
+		var projectPolicy ProjectPolicy
+		if err := json.Unmarshal(pfile.content, &projectPolicy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal: %w", err)
+		}
+		if err := validateProjectPolicy(orgPolicy, projectPolicy); err != nil {
+			return nil, err
+		}
+		// Repositories are verified and attested in their canonical form.
+		// NOTE: the repository was validated above.
+		projectPolicy.Build.Repository.URI, _ = sourceuri.Normalize(projectPolicy.Build.Repository.URI)
+		// Packages are looked up and attested by their canonical name.
+		// NOTE: the name was validated above.
+		name, _ := packageName(projectPolicy.Package.Name)
+		projectPolicy.Package.Name = name
+		if _, exists := projectPolicies[name]; exists {
+			return nil, fmt.Errorf("%q policy: duplicate package %q", contextProject, name)
+		}
+		projectPolicies[name] = projectPolicy
+		projectFiles[name] = intoto.Descriptor(pfile.path, pfile.content)
+	}
+
+	return &Policy{
+		orgPolicy:       orgPolicy,
+		projectPolicies: projectPolicies,
+		orgFile:         intoto.Descriptor(org.path, org.content),
+		projectFiles:    projectFiles,
+	}, nil
+}
+
+// FromFiles reads the org policy and every project policy under dir.
+// The org file is skipped if it lives under dir.
+func FromFiles(org, dir string) (*Policy, error) {
+	orgContent, err := os.ReadFile(org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	orgPath, err := filepath.Abs(org)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	var projects []file
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path: %w", err)
+		}
+		if abs == orgPath {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		projects = append(projects, file{path: path, content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromFiles(file{path: org, content: orgContent}, projects)
+}
+
+func validateOrgPolicy(p OrgPolicy) error {
+	if p.Format != 1 {
+		return fmt.Errorf("%q policy: invalid %q", contextOrg, "format")
+	}
+	if len(p.Roots.Build) == 0 {
+		return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.build")
+	}
+	names := make(map[string]bool, len(p.Roots.Build))
+	for i := range p.Roots.Build {
+		root := &p.Roots.Build[i]
+		if root.ID == "" {
+			return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.build.id")
+		}
+		if root.Name == "" {
+			return fmt.Errorf("%q policy: empty %q", contextOrg, "roots.build.name")
+		}
+		if root.SlsaLevel < 0 || root.SlsaLevel > 4 {
+			return fmt.Errorf("%q policy: invalid %q: %d", contextOrg, "roots.build.slsa_level", root.SlsaLevel)
+		}
+		if names[root.Name] {
+			return fmt.Errorf("%q policy: duplicate %q: %q", contextOrg, "roots.build.name", root.Name)
+		}
+		names[root.Name] = true
+	}
+	return nil
+}
+
+func validateProjectPolicy(org OrgPolicy, p ProjectPolicy) error {
+	if p.Format != 1 {
+		return fmt.Errorf("%q policy: invalid %q", contextProject, "format")
+	}
+	if p.Package.Name == "" {
+		return fmt.Errorf("%q policy: empty %q", contextProject, "package.name")
+	}
+	if _, err := packageName(p.Package.Name); err != nil {
+		return fmt.Errorf("%q policy: invalid %q: %w", contextProject, "package.name", err)
+	}
+	if p.Package.Environment != nil && len(p.Package.Environment.AnyOf) == 0 {
+		return fmt.Errorf("%q policy %q: empty %q", contextProject, p.Package.Name, "package.environment.any_of")
+	}
+	if p.Build.Repository.URI == "" {
+		return fmt.Errorf("%q policy %q: empty %q", contextProject, p.Package.Name, "build.repository.uri")
+	}
+	if _, err := sourceuri.Parse(p.Build.Repository.URI); err != nil {
+		return fmt.Errorf("%q policy %q: invalid %q: %w", contextProject, p.Package.Name, "build.repository.uri", err)
+	}
+	if findRoot(org, p.Build.RequireSlsaBuilder) == nil {
+		return fmt.Errorf("%q policy %q: unknown builder %q", contextProject, p.Package.Name, p.Build.RequireSlsaBuilder)
+	}
+	return nil
+}
+
+func findRoot(org OrgPolicy, name string) *Root {
+	for i := range org.Roots.Build {
+		root := &org.Roots.Build[i]
+		if root.Name == name {
+			return root
+		}
+	}
+	return nil
+}
+
+func (p *Policy) Evaluate(imageURI, releaserID, environment string, verifier options.BuildVerifier) results.Verification {
+	_, result := p.evaluate(imageURI, releaserID, environment, verifier)
+	return result
+}
+
+// Attest evaluates the policy and returns the release attestation
+// of the image if the release passes.
+func (p *Policy) Attest(imageURI, releaserID, environment string, verifier options.BuildVerifier,
+	creationTime time.Time,
+) (*attestation.Statement, results.Verification) {
+	statement, result := p.evaluate(imageURI, releaserID, environment, verifier)
+	if !result.Pass() {
+		return nil, result
+	}
+	statement.Predicate.CreationTime = creationTime.UTC().Format(time.RFC3339)
+	return statement, result
+}
+
+// evaluate evaluates the policy. If the release passes, it returns
+// the release attestation without its creation time.
+func (p *Policy) evaluate(imageURI, releaserID, environment string, verifier options.BuildVerifier) (*attestation.Statement, results.Verification) {
+	if verifier == nil {
+		return nil, results.VerificationInvalid(errors.New("no build verifier"))
+	}
+	if releaserID == "" {
+		return nil, results.VerificationInvalid(errors.New("empty releaser ID"))
+	}
+	imageName, digest, err := parseImage(imageURI)
+	if err != nil {
+		return nil, results.VerificationInvalid(err)
+	}
+
+	project, ok := p.projectPolicies[imageName]
+	if !ok {
+		return nil, results.VerificationFail(fmt.Errorf("%q: no policy for package %q", contextProject, imageName))
+	}
+
+	// 1. Verify the environment.
+	if err := verifyEnvironment(project.Package.Environment, environment); err != nil {
+		return nil, results.VerificationFail(fmt.Errorf("%q: %w", contextProject, err))
+	}
+
+	// 2. Verify the build.
+	// NOTE: the builder was validated when the policy was loaded.
+	root := findRoot(p.orgPolicy, project.Build.RequireSlsaBuilder)
+	if err := verifier.VerifyBuildAttestation(digest, imageName, root.ID, project.Build.Repository.URI); err != nil {
+		return nil, results.VerificationFail(fmt.Errorf("%q: build verification: %w", contextProject, err))
+	}
+
+	alg, value, _ := strings.Cut(digest, ":")
+	subject := intoto.ResourceDescriptor{
+		Name:   imageName,
+		Digest: map[string]string{alg: value},
+	}
+	return &attestation.Statement{
+		Type:          intoto.StatementType,
+		Subject:       []intoto.ResourceDescriptor{subject},
+		PredicateType: attestation.PredicateType,
+		Predicate: attestation.Predicate{
+			Package: attestation.Package{
+				Name:        imageName,
+				Environment: environment,
+			},
+			Releaser: attestation.Releaser{
+				ID: releaserID,
+			},
+			Build: attestation.Build{
+				Repository: attestation.Repository{
+					URI: project.Build.Repository.URI,
+				},
+				Builder: attestation.Builder{
+					ID:   root.ID,
+					Name: root.Name,
+				},
+				SlsaLevel: root.SlsaLevel,
+			},
+			Policy: []intoto.ResourceDescriptor{p.orgFile, p.projectFiles[imageName]},
+		},
+	}, results.VerificationPass()
+}
+
+func verifyEnvironment(policyEnv *Environment, environment string) error {
+	if policyEnv == nil {
+		if environment != "" {
+			return fmt.Errorf("environment %q not allowed", environment)
+		}
+		return nil
+	}
+	if environment == "" {
+		return errors.New("empty environment")
+	}
+	for i := range policyEnv.AnyOf {
+		if policyEnv.AnyOf[i] == environment {
+			return nil
+		}
+	}
+	return fmt.Errorf("environment %q not allowed", environment)
+}
+
+// parseImage returns the canonical name and the digest of an image.
+// The tag, if any, is dropped.
+func parseImage(imageURI string) (string, string, error) {
+	ref, err := imageref.Parse(imageURI)
+	if err != nil {
+		return "", "", err
+	}
+	if ref.Digest == "" {
+		return "", "", fmt.Errorf("image %q: no digest", imageURI)
+	}
+	return ref.Name(), ref.Digest, nil
+}
+
+// packageName returns the canonical name of a package, which is
+// an image without a tag or a digest.
+func packageName(name string) (string, error) {
+	ref, err := imageref.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	if ref.Tag != "" || ref.Digest != "" {
+		return "", fmt.Errorf("image %q: unexpected tag or digest", name)
+	}
+	return ref.Name(), nil
+}
@@ -0,0 +1,351 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/attestation"
+)
+
+const testOrg = `{
+    "format": 1,
+    "roots": {
+        "build": [
+            {
+                "id": "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml",
+                "name": "github_generator_level_3",
+                "slsa_level": 3
+            }
+        ]
+    }
+}`
+
+const testProject = `{
+    "format": 1,
+    "package": {
+        "name": "docker.io/org/echo-server",
+        "environment": {
+            "any_of": ["staging", "prod"]
+        }
+    },
+    "build": {
+        "require_slsa_builder": "github_generator_level_3",
+        "repository": {
+            "uri": "github.com/org/echo"
+        }
+    }
+}`
+
+const testProjectNoEnv = `{
+    "format": 1,
+    "package": {
+        "name": "docker.io/org/database-server"
+    },
+    "build": {
+        "require_slsa_builder": "github_generator_level_3",
+        "repository": {
+            "uri": "github.com/org/database"
+        }
+    }
+}`
+
+type testVerifier struct {
+	builderID string
+	sourceURI string
+}
+
+func (v testVerifier) VerifyBuildAttestation(digest, imageName, builderID, sourceURI string) error {
+	if builderID != v.builderID || sourceURI != v.sourceURI {
+		return errors.New("mismatch")
+	}
+	return nil
+}
+
+func Test_FromBytes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		org      string
+		projects []string
+		expected bool
+	}{
+		{
+			name:     "valid policies",
+			org:      testOrg,
+			projects: []string{testProject, testProjectNoEnv},
+			expected: true,
+		},
+		{
+			name:     "invalid org format",
+			org:      `{"format": 2, "roots": {"build": [{"id": "id", "name": "name"}]}}`,
+			expected: false,
+		},
+		{
+			name:     "empty org roots",
+			org:      `{"format": 1}`,
+			expected: false,
+		},
+		{
+			name:     "duplicate packages",
+			org:      testOrg,
+			projects: []string{testProject, testProject},
+			expected: false,
+		},
+		{
+			name: "duplicate packages in another spelling",
+			org:  testOrg,
+			projects: []string{testProject, `{
+				"format": 1,
+				"package": {"name": "index.docker.io/org/echo-server"},
+				"build": {
+					"require_slsa_builder": "github_generator_level_3",
+					"repository": {"uri": "github.com/org/echo"}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "package with a tag",
+			org:  testOrg,
+			projects: []string{`{
+				"format": 1,
+				"package": {"name": "docker.io/org/echo-server:v1"},
+				"build": {
+					"require_slsa_builder": "github_generator_level_3",
+					"repository": {"uri": "github.com/org/echo"}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "unknown builder",
+			org:  testOrg,
+			projects: []string{`{
+				"format": 1,
+				"package": {"name": "docker.io/org/echo-server"},
+				"build": {
+					"require_slsa_builder": "unknown",
+					"repository": {"uri": "github.com/org/echo"}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "invalid repository",
+			org:  testOrg,
+			projects: []string{`{
+				"format": 1,
+				"package": {"name": "docker.io/org/echo-server"},
+				"build": {
+					"require_slsa_builder": "github_generator_level_3",
+					"repository": {"uri": "github.com/org/echo@main"}
+				}
+			}`},
+			expected: false,
+		},
+		{
+			name: "empty environment list",
+			org:  testOrg,
+			projects: []string{`{
+				"format": 1,
+				"package": {"name": "docker.io/org/echo-server", "environment": {"any_of": []}},
+				"build": {
+					"require_slsa_builder": "github_generator_level_3",
+					"repository": {"uri": "github.com/org/echo"}
+				}
+			}`},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			projects := make([][]byte, len(tt.projects))
+			for i := range tt.projects {
+				projects[i] = []byte(tt.projects[i])
+			}
+			_, err := FromBytes([]byte(tt.org), projects)
+			if diff := cmp.Diff(tt.expected, err == nil); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", err, diff)
+			}
+		})
+	}
+}
+
+func Test_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	verifier := testVerifier{
+		builderID: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml",
+		sourceURI: "git+https://github.com/org/echo",
+	}
+	tests := []struct {
+		name        string
+		imageURI    string
+		releaserID  string
+		environment string
+		expected    func(results.Verification) bool
+	}{
+		{
+			name:        "pass",
+			imageURI:    "docker.io/org/echo-server@sha256:abcd",
+			releaserID:  "releaser",
+			environment: "prod",
+			expected:    results.Verification.Pass,
+		},
+		{
+			name:        "other spelling of the image",
+			imageURI:    "index.docker.io/org/echo-server:v1@sha256:abcd",
+			releaserID:  "releaser",
+			environment: "prod",
+			expected:    results.Verification.Pass,
+		},
+		{
+			name:        "environment not allowed",
+			imageURI:    "docker.io/org/echo-server@sha256:abcd",
+			releaserID:  "releaser",
+			environment: "dev",
+			expected:    results.Verification.Fail,
+		},
+		{
+			name:       "environment required",
+			imageURI:   "docker.io/org/echo-server@sha256:abcd",
+			releaserID: "releaser",
+			expected:   results.Verification.Fail,
+		},
+		{
+			name:        "environment not declared",
+			imageURI:    "docker.io/org/database-server@sha256:abcd",
+			releaserID:  "releaser",
+			environment: "prod",
+			expected:    results.Verification.Fail,
+		},
+		{
+			name:       "build verification failure",
+			imageURI:   "docker.io/org/database-server@sha256:abcd",
+			releaserID: "releaser",
+			expected:   results.Verification.Fail,
+		},
+		{
+			name:        "unknown package",
+			imageURI:    "docker.io/org/unknown@sha256:abcd",
+			releaserID:  "releaser",
+			environment: "prod",
+			expected:    results.Verification.Fail,
+		},
+		{
+			name:        "no digest",
+			imageURI:    "docker.io/org/echo-server",
+			releaserID:  "releaser",
+			environment: "prod",
+			expected:    results.Verification.Invalid,
+		},
+		{
+			name:        "empty releaser",
+			imageURI:    "docker.io/org/echo-server@sha256:abcd",
+			environment: "prod",
+			expected:    results.Verification.Invalid,
+		},
+	}
+	policy, err := FromBytes([]byte(testOrg), [][]byte{[]byte(testProject), []byte(testProjectNoEnv)})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := policy.Evaluate(tt.imageURI, tt.releaserID, tt.environment, verifier)
+			if !tt.expected(result) {
+				t.Fatalf("unexpected result: %v", result)
+			}
+		})
+	}
+}
+
+func Test_Attest(t *testing.T) {
+	t.Parallel()
+
+	verifier := testVerifier{
+		builderID: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml",
+		sourceURI: "git+https://github.com/org/echo",
+	}
+	creationTime := time.Date(2023, 7, 1, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	policy, err := fromFiles(
+		file{path: "org.json", content: []byte(testOrg)},
+		[]file{
+			{path: "echo.json", content: []byte(testProject)},
+			{path: "database.json", content: []byte(testProjectNoEnv)},
+		})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		imageURI    string
+		environment string
+		expected    *attestation.Statement
+	}{
+		{
+			name:        "pass",
+			imageURI:    "docker.io/org/echo-server@sha256:abcd",
+			environment: "prod",
+			expected: &attestation.Statement{
+				Type: intoto.StatementType,
+				Subject: []intoto.ResourceDescriptor{
+					{Name: "docker.io/org/echo-server", Digest: map[string]string{"sha256": "abcd"}},
+				},
+				PredicateType: attestation.PredicateType,
+				Predicate: attestation.Predicate{
+					CreationTime: "2023-07-01T18:00:00Z",
+					Package: attestation.Package{
+						Name:        "docker.io/org/echo-server",
+						Environment: "prod",
+					},
+					Releaser: attestation.Releaser{ID: "releaser"},
+					Build: attestation.Build{
+						Repository: attestation.Repository{URI: "git+https://github.com/org/echo"},
+						Builder: attestation.Builder{
+							ID:   "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml",
+							Name: "github_generator_level_3",
+						},
+						SlsaLevel: 3,
+					},
+					Policy: []intoto.ResourceDescriptor{
+						intoto.Descriptor("org.json", []byte(testOrg)),
+						intoto.Descriptor("echo.json", []byte(testProject)),
+					},
+				},
+			},
+		},
+		{
+			name:        "fail",
+			imageURI:    "docker.io/org/echo-server@sha256:abcd",
+			environment: "dev",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			statement, result := policy.Attest(tt.imageURI, "releaser", tt.environment, verifier, creationTime)
+			if diff := cmp.Diff(tt.expected != nil, result.Pass()); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", result, diff)
+			}
+			if diff := cmp.Diff(tt.expected, statement); diff != "" {
+				t.Fatalf("unexpected statement (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package options
+
+// BuildVerifier verifies build attestations.
+type BuildVerifier interface {
+	// VerifyBuildAttestation verifies that the image identified by
+	// imageName and digest was built by builderID from sourceURI.
+	// The source URI is canonical, e.g. git+https://github.com/org/repo,
+	// and may carry a ref.
+	VerifyBuildAttestation(digest, imageName, builderID, sourceURI string) error
+}
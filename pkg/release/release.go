@@ -0,0 +1,40 @@
+package release
+
+import (
+	"time"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/attestation"
+	internal "github.com/laurentsimon/slsa-e2e/pkg/release/internal"
+	"github.com/laurentsimon/slsa-e2e/pkg/release/options"
+)
+
+// Policy defines a release policy.
+type Policy struct {
+	policy *internal.Policy
+}
+
+// FromFiles builds a policy from an org file and a directory
+// containing the package files.
+func FromFiles(org, dir string) (*Policy, error) {
+	policy, err := internal.FromFiles(org, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{
+		policy: policy,
+	}, nil
+}
+
+// Evaluate evaluates the policy for an image of the form name@digest.
+func (p *Policy) Evaluate(imageURI, releaserID, environment string, verifier options.BuildVerifier) results.Verification {
+	return p.policy.Evaluate(imageURI, releaserID, environment, verifier)
+}
+
+// Attest evaluates the policy for an image of the form name@digest.
+// If the release passes, it returns the release attestation of the image.
+func (p *Policy) Attest(imageURI, releaserID, environment string, verifier options.BuildVerifier,
+	creationTime time.Time,
+) (*attestation.Statement, results.Verification) {
+	return p.policy.Attest(imageURI, releaserID, environment, verifier, creationTime)
+}
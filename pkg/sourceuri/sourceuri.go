@@ -0,0 +1,186 @@
+// Package sourceuri canonicalizes source repository URIs, so that the
+// spellings of the same repository compare equal: github.com/org/repo,
+// https://github.com/org/repo.git and git+https://github.com/org/repo
+// are all git+https://github.com/org/repo.
+package sourceuri
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultScheme is the scheme of sources given as a host and a path.
+	DefaultScheme = "git+https"
+	refsPrefix    = "refs/"
+	branchPrefix  = "refs/heads/"
+	tagPrefix     = "refs/tags/"
+)
+
+// schemeAliases are the schemes of URLs that designate a git repository.
+var schemeAliases = map[string]string{
+	"https": "git+https",
+	"http":  "git+http",
+}
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// Reference is a canonical source reference.
+type Reference struct {
+	// Scheme is the lowercase scheme, e.g. git+https.
+	Scheme string
+	// Host is the lowercase host, e.g. github.com.
+	Host string
+	// Path is the path of the repository without the .git suffix,
+	// e.g. org/repo.
+	Path string
+	// Ref is the ref, e.g. refs/heads/main, refs/tags/v1.0.0,
+	// or a commit, if any.
+	Ref string
+}
+
+// Parse parses a source URI of the form
+// [scheme://]host/path[.git][@ref], where the ref is a commit or
+// a ref under refs/, e.g. refs/heads/branch, refs/tags/tag or
+// refs/pull/1/merge.
+func Parse(uri string) (Reference, error) {
+	r := split(uri)
+	if r.Host == "" {
+		return Reference{}, fmt.Errorf("source %q: empty host", uri)
+	}
+	if r.Path == "" {
+		return Reference{}, fmt.Errorf("source %q: empty repository", uri)
+	}
+	for _, component := range strings.Split(r.Path, "/") {
+		if component == "" {
+			return Reference{}, fmt.Errorf("source %q: empty path component", uri)
+		}
+	}
+	if strings.ContainsAny(r.Scheme+r.Host+r.Path, " \t\n") {
+		return Reference{}, fmt.Errorf("source %q: invalid repository", uri)
+	}
+	if r.Ref != "" && !validRef(r.Ref) {
+		return Reference{}, fmt.Errorf("source %q: invalid ref %q", uri, r.Ref)
+	}
+	return r, nil
+}
+
+// Normalize returns the canonical form of a source URI.
+func Normalize(uri string) (string, error) {
+	r, err := Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// NormalizePattern returns the canonical form of a pattern of source URIs.
+// Globs are kept as they are.
+func NormalizePattern(pattern string) string {
+	if pattern == "" || strings.Trim(pattern, "*") == "" {
+		return pattern
+	}
+	return split(pattern).String()
+}
+
+// ParsePattern returns the parts of a pattern of source URIs,
+// without validating them.
+func ParsePattern(pattern string) Reference {
+	return split(pattern)
+}
+
+// split splits a source URI into its parts without validating them.
+func split(uri string) Reference {
+	var r Reference
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		scheme, rest = DefaultScheme, uri
+	}
+	scheme = strings.ToLower(scheme)
+	if alias, ok := schemeAliases[scheme]; ok {
+		scheme = alias
+	}
+	r.Scheme = scheme
+
+	// The ref follows the first @ after the host: an @ in the host
+	// separates the user, e.g. git@github.com.
+	host, path, _ := strings.Cut(rest, "/")
+	if name, ref, ok := strings.Cut(path, "@"); ok {
+		path, r.Ref = name, ref
+	}
+	r.Host = strings.ToLower(host)
+	path = strings.TrimSuffix(path, "/")
+	r.Path = strings.TrimSuffix(path, ".git")
+	return r
+}
+
+// validRef returns true if the ref is a commit, or a ref under refs/
+// that is well-formed as git check-ref-format defines it.
+func validRef(ref string) bool {
+	name, ok := strings.CutPrefix(ref, refsPrefix)
+	if !ok {
+		return commitRegexp.MatchString(ref)
+	}
+	if strings.ContainsAny(ref, " ~^:?*[\\") || strings.Contains(ref, "..") ||
+		strings.Contains(ref, "@{") || strings.HasSuffix(ref, ".") {
+		return false
+	}
+	for _, c := range ref {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// Repository returns the URI of the repository, without the ref.
+func (r Reference) Repository() string {
+	s := r.Scheme + "://" + r.Host
+	if r.Path != "" {
+		s += "/" + r.Path
+	}
+	return s
+}
+
+// Location returns the host and the path of the repository,
+// e.g. github.com/org/repo, as slsa-verifier expects it.
+func (r Reference) Location() string {
+	return r.Host + "/" + r.Path
+}
+
+// Branch returns the branch of the ref, if the ref is a branch.
+func (r Reference) Branch() string {
+	if !strings.HasPrefix(r.Ref, branchPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(r.Ref, branchPrefix)
+}
+
+// Tag returns the tag of the ref, if the ref is a tag.
+func (r Reference) Tag() string {
+	if !strings.HasPrefix(r.Ref, tagPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(r.Ref, tagPrefix)
+}
+
+// String returns the canonical form of the reference.
+func (r Reference) String() string {
+	s := r.Repository()
+	if r.Ref != "" {
+		s += "@" + r.Ref
+	}
+	return s
+}
+
+// WithoutRef returns the reference without its ref.
+func (r Reference) WithoutRef() Reference {
+	r.Ref = ""
+	return r
+}
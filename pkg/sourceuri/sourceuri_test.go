@@ -0,0 +1,127 @@
+package sourceuri
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testCommit = "2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a"
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	repo := Reference{Scheme: "git+https", Host: "github.com", Path: "org/repo"}
+	withRef := func(ref string) *Reference {
+		r := repo
+		r.Ref = ref
+		return &r
+	}
+	tests := []struct {
+		uri      string
+		expected *Reference
+	}{
+		{uri: "git+https://github.com/org/repo", expected: &repo},
+		{uri: "https://github.com/org/repo", expected: &repo},
+		{uri: "github.com/org/repo", expected: &repo},
+		{uri: "https://github.com/org/repo.git", expected: &repo},
+		{uri: "git+https://GitHub.com/org/repo/", expected: &repo},
+		{uri: "git+https://github.com/org/repo@refs/heads/main", expected: withRef("refs/heads/main")},
+		{uri: "github.com/org/repo.git@refs/heads/feature/x", expected: withRef("refs/heads/feature/x")},
+		{uri: "https://github.com/org/repo@refs/tags/v1.0.0", expected: withRef("refs/tags/v1.0.0")},
+		{uri: "git+https://github.com/org/repo@" + testCommit, expected: withRef(testCommit)},
+		{uri: "github.com/org/repo@refs/pull/1/merge", expected: withRef("refs/pull/1/merge")},
+		{uri: "github.com/org/repo@refs/remotes/origin/main", expected: withRef("refs/remotes/origin/main")},
+		{
+			uri:      "git+ssh://git@gitlab.com/group/sub/repo.git",
+			expected: &Reference{Scheme: "git+ssh", Host: "git@gitlab.com", Path: "group/sub/repo"},
+		},
+		{uri: ""},
+		{uri: "github.com"},
+		{uri: "https://github.com/"},
+		{uri: "github.com/org//repo"},
+		{uri: "github.com/org/repo@main"},
+		{uri: "github.com/org/repo@refs/heads/"},
+		{uri: "github.com/org/repo@refs/"},
+		{uri: "github.com/org/repo@refs/heads//main"},
+		{uri: "github.com/org/repo@refs/heads/a..b"},
+		{uri: "github.com/org/repo@refs/heads/.main"},
+		{uri: "github.com/org/repo@refs/heads/main.lock"},
+		{uri: "github.com/org/repo@refs/heads/main."},
+		{uri: "github.com/org/repo@refs/heads/main@{1}"},
+		{uri: "github.com/org/repo@refs/heads/ma*in"},
+		{uri: "github.com/org/repo@refs/heads/ma in"},
+		{uri: "github.com/org/repo name"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.uri)
+		if diff := cmp.Diff(tt.expected != nil, err == nil); diff != "" {
+			t.Errorf("%q: unexpected error (-want +got): %v\n%s", tt.uri, err, diff)
+			continue
+		}
+		if tt.expected != nil {
+			if diff := cmp.Diff(*tt.expected, r); diff != "" {
+				t.Errorf("%q: unexpected reference (-want +got): \n%s", tt.uri, diff)
+			}
+		}
+	}
+}
+
+func Test_Reference(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		uri                               string
+		repository, location, branch, tag string
+	}{
+		{
+			uri:        "github.com/org/repo@refs/heads/release/v1",
+			repository: "git+https://github.com/org/repo",
+			location:   "github.com/org/repo",
+			branch:     "release/v1",
+		},
+		{
+			uri:        "https://github.com/org/repo.git@refs/tags/v1.2.3",
+			repository: "git+https://github.com/org/repo",
+			location:   "github.com/org/repo",
+			tag:        "v1.2.3",
+		},
+		{
+			uri:        "git+https://github.com/org/repo@" + testCommit,
+			repository: "git+https://github.com/org/repo",
+			location:   "github.com/org/repo",
+		},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.uri)
+		if err != nil {
+			t.Errorf("%q: %v", tt.uri, err)
+			continue
+		}
+		got := []string{r.Repository(), r.Location(), r.Branch(), r.Tag()}
+		expected := []string{tt.repository, tt.location, tt.branch, tt.tag}
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("%q: unexpected parts (-want +got): \n%s", tt.uri, diff)
+		}
+	}
+}
+
+func Test_NormalizePattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern, expected string
+	}{
+		{pattern: "*", expected: "*"},
+		{pattern: "", expected: ""},
+		{pattern: "git+https://github.com/org/*", expected: "git+https://github.com/org/*"},
+		{pattern: "github.com/laurentsimon/slsa-project", expected: "git+https://github.com/laurentsimon/slsa-project"},
+		{pattern: "https://github.com/org/*.git", expected: "git+https://github.com/org/*"},
+		{pattern: "github.com/org/repo@refs/tags/*", expected: "git+https://github.com/org/repo@refs/tags/*"},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.expected, NormalizePattern(tt.pattern)); diff != "" {
+			t.Errorf("%q: unexpected result (-want +got): \n%s", tt.pattern, diff)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Directory stores attestations in a directory. An attestation is a file
+// named after its SHA256 digest, under alg/value/predicate-type, where the
+// predicate type is escaped.
+type Directory struct {
+	root string
+}
+
+var _ AttestationStore = (*Directory)(nil)
+
+// NewDirectory returns the store in the directory root. The directory
+// is created by the first Put.
+func NewDirectory(root string) *Directory {
+	return &Directory{root: root}
+}
+
+func (d *Directory) path(digest, predicateType string) (string, error) {
+	alg, value, err := parseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	if err := validatePredicateType(predicateType); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.root, alg, value, url.PathEscape(predicateType)), nil
+}
+
+// Put stores the attestation.
+func (d *Directory) Put(digest, predicateType string, attestation []byte) error {
+	dir, err := d.path(digest, predicateType)
+	if err != nil {
+		return err
+	}
+	if err := validate(predicateType, attestation); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	sum := sha256.Sum256(attestation)
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+	if err := os.WriteFile(path, attestation, 0o600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// Get returns the attestations, sorted by digest.
+func (d *Directory) Get(digest, predicateType string) ([][]byte, error) {
+	dir, err := d.path(digest, predicateType)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	var attestations [][]byte
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		attestations = append(attestations, content)
+	}
+	return attestations, nil
+}
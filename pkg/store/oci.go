@@ -0,0 +1,317 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+)
+
+// Media types and annotations of the OCI image layout.
+const (
+	mediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeEnvelope = "application/vnd.dsse.envelope.v1+json"
+
+	annotationRefName       = "org.opencontainers.image.ref.name"
+	annotationPredicateType = "predicateType"
+
+	layoutFile    = "oci-layout"
+	indexFile     = "index.json"
+	layoutVersion = "1.0.0"
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+type rootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type config struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	Config       struct{} `json:"config"`
+	RootFS       rootFS   `json:"rootfs"`
+}
+
+// OCILayout stores attestations in an OCI image layout, following the
+// convention of cosign: the attestations of a digest alg:value are the
+// layers of the image tagged alg-value.att, annotated with their
+// predicate type. A layout can be copied to and from a registry.
+type OCILayout struct {
+	root string
+}
+
+var _ AttestationStore = (*OCILayout)(nil)
+
+// NewOCILayout returns the store in the layout directory root.
+// The layout is created by the first Put.
+func NewOCILayout(root string) *OCILayout {
+	return &OCILayout{root: root}
+}
+
+// tag returns the tag of the attestations of a digest.
+func tag(digest string) (string, error) {
+	alg, value, err := parseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return alg + "-" + value + ".att", nil
+}
+
+// Put adds the attestation to the image of the digest.
+// The layout must not be written concurrently.
+func (l *OCILayout) Put(digest, predicateType string, attestation []byte) error {
+	ref, err := tag(digest)
+	if err != nil {
+		return err
+	}
+	if err := validate(predicateType, attestation); err != nil {
+		return err
+	}
+	if err := l.init(); err != nil {
+		return err
+	}
+	idx, err := l.readIndex()
+	if err != nil {
+		return err
+	}
+
+	var m manifest
+	pos := findManifest(idx, ref)
+	if pos >= 0 {
+		if err := l.readJSON(idx.Manifests[pos].Digest, &m); err != nil {
+			return err
+		}
+	} else {
+		m = manifest{SchemaVersion: 2, MediaType: mediaTypeManifest}
+	}
+
+	layer, err := l.writeBlob(attestation)
+	if err != nil {
+		return err
+	}
+	for i := range m.Layers {
+		if m.Layers[i].Digest == layer.Digest && m.Layers[i].Annotations[annotationPredicateType] == predicateType {
+			return nil
+		}
+	}
+	layer.MediaType = layerMediaType(attestation)
+	layer.Annotations = map[string]string{annotationPredicateType: predicateType}
+	m.Layers = append(m.Layers, layer)
+
+	c := config{RootFS: rootFS{Type: "layers", DiffIDs: []string{}}}
+	for i := range m.Layers {
+		c.RootFS.DiffIDs = append(c.RootFS.DiffIDs, m.Layers[i].Digest)
+	}
+	m.Config, err = l.writeJSON(c)
+	if err != nil {
+		return err
+	}
+	m.Config.MediaType = mediaTypeConfig
+
+	desc, err := l.writeJSON(m)
+	if err != nil {
+		return err
+	}
+	desc.MediaType = mediaTypeManifest
+	desc.Annotations = map[string]string{annotationRefName: ref}
+	if pos >= 0 {
+		idx.Manifests[pos] = desc
+	} else {
+		idx.Manifests = append(idx.Manifests, desc)
+	}
+	return l.writeIndex(idx)
+}
+
+// Get returns the attestations with the predicate type, in the order
+// they were added.
+func (l *OCILayout) Get(digest, predicateType string) ([][]byte, error) {
+	ref, err := tag(digest)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePredicateType(predicateType); err != nil {
+		return nil, err
+	}
+	idx, err := l.readIndex()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pos := findManifest(idx, ref)
+	if pos < 0 {
+		return nil, nil
+	}
+	var m manifest
+	if err := l.readJSON(idx.Manifests[pos].Digest, &m); err != nil {
+		return nil, err
+	}
+
+	var attestations [][]byte
+	for i := range m.Layers {
+		layer := &m.Layers[i]
+		if layer.Annotations[annotationPredicateType] != predicateType {
+			continue
+		}
+		content, err := l.readBlob(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		attestations = append(attestations, content)
+	}
+	return attestations, nil
+}
+
+// layerMediaType returns the media type of an attestation: bundles
+// have their own media type, other attestations are DSSE envelopes.
+func layerMediaType(attestation []byte) string {
+	if _, bundle, err := dsse.Parse(attestation); err == nil && bundle != nil && bundle.MediaType != "" {
+		return bundle.MediaType
+	}
+	return mediaTypeEnvelope
+}
+
+func findManifest(idx *index, ref string) int {
+	for i := range idx.Manifests {
+		if idx.Manifests[i].Annotations[annotationRefName] == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// init creates the layout if it does not exist.
+func (l *OCILayout) init() error {
+	path := filepath.Join(l.root, layoutFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(l.root, "blobs", "sha256"), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	content, err := json.Marshal(map[string]string{"imageLayoutVersion": layoutVersion})
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return l.writeIndex(&index{SchemaVersion: 2, MediaType: mediaTypeIndex, Manifests: []descriptor{}})
+}
+
+func (l *OCILayout) readIndex() (*index, error) {
+	content, err := os.ReadFile(filepath.Join(l.root, indexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	var idx index
+	if err := json.Unmarshal(content, &idx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index: %w", err)
+	}
+	return &idx, nil
+}
+
+func (l *OCILayout) writeIndex(idx *index) error {
+	content, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	// The index is replaced atomically.
+	tmp := filepath.Join(l.root, indexFile+".tmp")
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.root, indexFile)); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+func (l *OCILayout) blobPath(digest string) (string, error) {
+	alg, value, err := parseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, "blobs", alg, value), nil
+}
+
+func (l *OCILayout) readBlob(digest string) ([]byte, error) {
+	path, err := l.blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("blob %q: digest mismatch", digest)
+	}
+	return content, nil
+}
+
+func (l *OCILayout) readJSON(digest string, v any) error {
+	content, err := l.readBlob(digest)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to unmarshal blob %q: %w", digest, err)
+	}
+	return nil
+}
+
+// writeBlob writes a blob and returns its descriptor without media type.
+func (l *OCILayout) writeBlob(content []byte) (descriptor, error) {
+	sum := sha256.Sum256(content)
+	desc := descriptor{
+		Digest: "sha256:" + hex.EncodeToString(sum[:]),
+		Size:   int64(len(content)),
+	}
+	path, err := l.blobPath(desc.Digest)
+	if err != nil {
+		return descriptor{}, err
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return descriptor{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	return desc, nil
+}
+
+func (l *OCILayout) writeJSON(v any) (descriptor, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return descriptor{}, fmt.Errorf("failed to marshal: %w", err)
+	}
+	return l.writeBlob(content)
+}
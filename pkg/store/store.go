@@ -0,0 +1,79 @@
+// Package store stores attestations by subject digest and predicate type,
+// so that they can be verified without access to a registry.
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/dsse"
+)
+
+// AttestationStore stores attestations by the digest of their subjects
+// and their predicate type. Digests are of the form alg:value.
+type AttestationStore interface {
+	// Put stores an attestation, a DSSE envelope or a Sigstore bundle.
+	// Storing the same attestation twice has no effect.
+	Put(digest, predicateType string, attestation []byte) error
+	// Get returns the attestations with the predicate type for the digest.
+	// They are not verified.
+	Get(digest, predicateType string) ([][]byte, error)
+}
+
+// Open opens a store of the form dir:path or oci:path.
+func Open(ref string) (AttestationStore, error) {
+	kind, path, ok := strings.Cut(ref, ":")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("invalid store %q: expected dir:path or oci:path", ref)
+	}
+	switch kind {
+	case "dir":
+		return NewDirectory(path), nil
+	case "oci":
+		return NewOCILayout(path), nil
+	default:
+		return nil, fmt.Errorf("invalid store %q: unsupported type %q", ref, kind)
+	}
+}
+
+// digestLengths are the lengths of the hex digests by algorithm.
+var digestLengths = map[string]int{
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// parseDigest returns the algorithm and the hex value of a digest.
+func parseDigest(digest string) (string, string, error) {
+	alg, value, ok := strings.Cut(digest, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid digest %q", digest)
+	}
+	length, ok := digestLengths[alg]
+	if !ok {
+		return "", "", fmt.Errorf("digest %q: unsupported algorithm %q", digest, alg)
+	}
+	if _, err := hex.DecodeString(value); err != nil || len(value) != length || strings.ToLower(value) != value {
+		return "", "", fmt.Errorf("digest %q: invalid value", digest)
+	}
+	return alg, value, nil
+}
+
+func validatePredicateType(predicateType string) error {
+	if predicateType == "" {
+		return errors.New("empty predicate type")
+	}
+	return nil
+}
+
+// validate validates an attestation before it is stored.
+func validate(predicateType string, attestation []byte) error {
+	if err := validatePredicateType(predicateType); err != nil {
+		return err
+	}
+	if _, _, err := dsse.Parse(attestation); err != nil {
+		return fmt.Errorf("invalid attestation: %w", err)
+	}
+	return nil
+}
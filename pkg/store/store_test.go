@@ -0,0 +1,186 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testDigest      = "sha256:2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a"
+	otherDigest     = "sha256:4e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f"
+	vsaType         = "https://slsa.dev/verification_summary/v1"
+	provenanceType  = "https://slsa.dev/provenance/v1"
+	testEnvelope    = `{"payloadType": "application/vnd.in-toto+json", "payload": "e30=", "signatures": [{"sig": "c2ln"}]}`
+	otherEnvelope   = `{"payloadType": "application/vnd.in-toto+json", "payload": "e30=", "signatures": [{"sig": "b3RoZXI="}]}`
+	testBundle      = `{"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.2", "verificationMaterial": {"publicKey": {"hint": "key"}, "tlogEntries": []}, "dsseEnvelope": ` + testEnvelope + `}`
+	invalidDocument = `{"key": "value"}`
+)
+
+func Test_AttestationStore(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		store func(root string) AttestationStore
+	}{
+		{
+			name:  "directory",
+			store: func(root string) AttestationStore { return NewDirectory(root) },
+		},
+		{
+			name:  "oci layout",
+			store: func(root string) AttestationStore { return NewOCILayout(root) },
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := tt.store(filepath.Join(t.TempDir(), "store"))
+
+			// The store does not exist yet.
+			got, err := s.Get(testDigest, vsaType)
+			if err != nil {
+				t.Fatalf("failed to get: %v", err)
+			}
+			if len(got) != 0 {
+				t.Fatalf("unexpected attestations: %q", got)
+			}
+
+			for _, put := range []struct {
+				digest, predicateType, content string
+			}{
+				{testDigest, vsaType, testEnvelope},
+				{testDigest, vsaType, testBundle},
+				{testDigest, vsaType, testEnvelope},
+				{testDigest, provenanceType, otherEnvelope},
+				{otherDigest, vsaType, otherEnvelope},
+			} {
+				if err := s.Put(put.digest, put.predicateType, []byte(put.content)); err != nil {
+					t.Fatalf("failed to put: %v", err)
+				}
+			}
+
+			for _, get := range []struct {
+				digest, predicateType string
+				expected              []string
+			}{
+				{testDigest, vsaType, []string{testEnvelope, testBundle}},
+				{testDigest, provenanceType, []string{otherEnvelope}},
+				{otherDigest, vsaType, []string{otherEnvelope}},
+				{otherDigest, provenanceType, nil},
+			} {
+				got, err := s.Get(get.digest, get.predicateType)
+				if err != nil {
+					t.Fatalf("failed to get: %v", err)
+				}
+				var contents []string
+				for i := range got {
+					contents = append(contents, string(got[i]))
+				}
+				if diff := cmp.Diff(get.expected, contents, sortStrings()); diff != "" {
+					t.Fatalf("%s %s: unexpected attestations (-want +got): \n%s", get.digest, get.predicateType, diff)
+				}
+			}
+
+			if err := s.Put("sha256:abcd", vsaType, []byte(testEnvelope)); err == nil {
+				t.Fatalf("expected an error for an invalid digest")
+			}
+			if err := s.Put("md5:2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c", vsaType, []byte(testEnvelope)); err == nil {
+				t.Fatalf("expected an error for an unsupported algorithm")
+			}
+			if err := s.Put(testDigest, "", []byte(testEnvelope)); err == nil {
+				t.Fatalf("expected an error for an empty predicate type")
+			}
+			if err := s.Put(testDigest, vsaType, []byte(invalidDocument)); err == nil {
+				t.Fatalf("expected an error for an invalid attestation")
+			}
+		})
+	}
+}
+
+// sortStrings sorts the attestations of the directory store, returned
+// by digest rather than in the order they were added.
+func sortStrings() cmp.Option {
+	return cmp.Transformer("sort", func(in []string) []string {
+		out := append([]string(nil), in...)
+		sort.Strings(out)
+		return out
+	})
+}
+
+func Test_OCILayout_tag(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	s := NewOCILayout(root)
+	if err := s.Put(testDigest, vsaType, []byte(testBundle)); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+	if err := s.Put(testDigest, provenanceType, []byte(testEnvelope)); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, indexFile))
+	if err != nil {
+		t.Fatalf("failed to read index: %v", err)
+	}
+	var idx index
+	if err := json.Unmarshal(content, &idx); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if len(idx.Manifests) != 1 {
+		t.Fatalf("unexpected manifests: %v", idx.Manifests)
+	}
+	ref := idx.Manifests[0].Annotations[annotationRefName]
+	if diff := cmp.Diff("sha256-2f1b8a3e3f6c5d7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a.att", ref); diff != "" {
+		t.Fatalf("unexpected tag (-want +got): \n%s", diff)
+	}
+
+	var m manifest
+	if err := s.readJSON(idx.Manifests[0].Digest, &m); err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var layers [][]string
+	for i := range m.Layers {
+		layers = append(layers, []string{m.Layers[i].MediaType, m.Layers[i].Annotations[annotationPredicateType]})
+	}
+	expected := [][]string{
+		{"application/vnd.dev.sigstore.bundle+json;version=0.2", vsaType},
+		{mediaTypeEnvelope, provenanceType},
+	}
+	if diff := cmp.Diff(expected, layers); diff != "" {
+		t.Fatalf("unexpected layers (-want +got): \n%s", diff)
+	}
+}
+
+func Test_Open(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		ref      string
+		expected AttestationStore
+	}{
+		{ref: "dir:attestations", expected: NewDirectory("attestations")},
+		{ref: "oci:/tmp/layout", expected: NewOCILayout("/tmp/layout")},
+		{ref: "attestations"},
+		{ref: "dir:"},
+		{ref: "registry:ghcr.io/org/image"},
+	}
+	for _, tt := range tests {
+		s, err := Open(tt.ref)
+		if diff := cmp.Diff(tt.expected != nil, err == nil); diff != "" {
+			t.Errorf("%q: unexpected error (-want +got): %v\n%s", tt.ref, err, diff)
+			continue
+		}
+		if diff := cmp.Diff(tt.expected, s, cmp.AllowUnexported(Directory{}, OCILayout{})); diff != "" {
+			t.Errorf("%q: unexpected store (-want +got): \n%s", tt.ref, diff)
+		}
+	}
+}
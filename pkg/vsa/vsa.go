@@ -0,0 +1,175 @@
+// Package vsa generates Verification Summary Attestations (VSA)
+// from evaluation results.
+package vsa
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+// PredicateType is the type of the VSA v1 predicate.
+const PredicateType = "https://slsa.dev/verification_summary/v1"
+
+const slsaVersion = "1.0"
+
+// Verification results of the predicate.
+const (
+	ResultPassed = "PASSED"
+	ResultFailed = "FAILED"
+)
+
+// Kubernetes name syntax, see
+// https://kubernetes.io/docs/concepts/overview/working-with-objects/names/.
+var (
+	dnsLabel     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dnsSubdomain = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	labelName    = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+)
+
+type Verifier struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// Predicate is the VSA v1 predicate.
+type Predicate struct {
+	Verifier           Verifier                    `json:"verifier"`
+	TimeVerified       string                      `json:"timeVerified"`
+	ResourceURI        string                      `json:"resourceUri"`
+	Policy             intoto.ResourceDescriptor   `json:"policy"`
+	InputAttestations  []intoto.ResourceDescriptor `json:"inputAttestations,omitempty"`
+	VerificationResult string                      `json:"verificationResult"`
+	VerifiedLevels     []string                    `json:"verifiedLevels"`
+	SlsaVersion        string                      `json:"slsaVersion"`
+	// Metadata is the Kubernetes resource the image was verified for, if any.
+	Metadata *Metadata `json:"metadata,omitempty"`
+}
+
+// Metadata contains the namespace and the labels of the Kubernetes
+// resource the image was verified for.
+type Metadata struct {
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type Statement = intoto.Statement[Predicate]
+
+// Summary contains the inputs of a VSA besides the evaluation result.
+type Summary struct {
+	// Subject is the verified artifact.
+	Subject intoto.ResourceDescriptor
+	// ResourceURI is the URI of the verified artifact.
+	ResourceURI string
+	// VerifierID is the identity of the verifier.
+	VerifierID string
+	// Policy is the policy the artifact was verified against.
+	Policy intoto.ResourceDescriptor
+	// InputAttestations are the attestations used during the verification.
+	InputAttestations []intoto.ResourceDescriptor
+	// TimeVerified is the time of the verification.
+	TimeVerified time.Time
+	// Namespace is the Kubernetes namespace the image was verified for.
+	Namespace string
+	// Labels are the labels of the Kubernetes resource the image
+	// was verified for.
+	Labels map[string]string
+}
+
+// New creates a VSA statement for an evaluation result.
+// Only passing results verify levels. Audited results
+// are reported as failed, since the policy was violated.
+func New(summary Summary, result results.Result) (*Statement, error) {
+	if err := validate(summary); err != nil {
+		return nil, err
+	}
+
+	verificationResult := ResultFailed
+	verifiedLevels := []string{}
+	switch result.Decision {
+	case "pass":
+		verificationResult = ResultPassed
+		verifiedLevels = append(verifiedLevels, fmt.Sprintf("SLSA_BUILD_LEVEL_%d", result.BuildLevel))
+	case "fail", "audit":
+	default:
+		return nil, fmt.Errorf("invalid decision: %q", result.Decision)
+	}
+
+	var metadata *Metadata
+	if summary.Namespace != "" || len(summary.Labels) > 0 {
+		metadata = &Metadata{
+			Namespace: summary.Namespace,
+			Labels:    summary.Labels,
+		}
+	}
+
+	return &Statement{
+		Type:          intoto.StatementType,
+		Subject:       []intoto.ResourceDescriptor{summary.Subject},
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			Verifier: Verifier{
+				ID: summary.VerifierID,
+			},
+			TimeVerified:       summary.TimeVerified.UTC().Format(time.RFC3339),
+			ResourceURI:        summary.ResourceURI,
+			Policy:             summary.Policy,
+			InputAttestations:  summary.InputAttestations,
+			VerificationResult: verificationResult,
+			VerifiedLevels:     verifiedLevels,
+			SlsaVersion:        slsaVersion,
+			Metadata:           metadata,
+		},
+	}, nil
+}
+
+func validate(summary Summary) error {
+	if summary.Subject.Name == "" || len(summary.Subject.Digest) == 0 {
+		return errors.New("invalid subject")
+	}
+	if summary.ResourceURI == "" {
+		return fmt.Errorf("empty %q", "resourceUri")
+	}
+	if summary.VerifierID == "" {
+		return fmt.Errorf("empty %q", "verifier.id")
+	}
+	if summary.Policy.URI == "" {
+		return fmt.Errorf("empty %q", "policy.uri")
+	}
+	if summary.TimeVerified.IsZero() {
+		return fmt.Errorf("empty %q", "timeVerified")
+	}
+	if summary.Namespace != "" && !validName(dnsLabel, summary.Namespace, 63) {
+		return fmt.Errorf("invalid %q: %q", "metadata.namespace", summary.Namespace)
+	}
+	for k, v := range summary.Labels {
+		if !validLabelKey(k) {
+			return fmt.Errorf("invalid %q key: %q", "metadata.labels", k)
+		}
+		if v != "" && !validName(labelName, v, 63) {
+			return fmt.Errorf("invalid %q value: %q", "metadata.labels", v)
+		}
+	}
+	return nil
+}
+
+// validLabelKey returns true if the key is a Kubernetes label key:
+// a name with an optional DNS subdomain prefix.
+func validLabelKey(key string) bool {
+	prefix, name, ok := strings.Cut(key, "/")
+	if !ok {
+		name, prefix = prefix, ""
+	} else if !validName(dnsSubdomain, prefix, 253) {
+		return false
+	}
+	return validName(labelName, name, 63)
+}
+
+func validName(re *regexp.Regexp, name string, maxLength int) bool {
+	return len(name) <= maxLength && re.MatchString(name)
+}
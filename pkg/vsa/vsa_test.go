@@ -0,0 +1,213 @@
+package vsa
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/laurentsimon/slsa-e2e/pkg/intoto"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy"
+	"github.com/laurentsimon/slsa-e2e/pkg/policy/results"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+
+	summary := Summary{
+		Subject: intoto.ResourceDescriptor{
+			Name:   "docker.io/org/image",
+			Digest: map[string]string{"sha256": "abcd"},
+		},
+		ResourceURI:       "docker.io/org/image",
+		VerifierID:        "https://github.com/org/verifier",
+		Policy:            intoto.Descriptor("git+https://github.com/org/repo/policy.json@refs/heads/main", []byte("{}")),
+		InputAttestations: []intoto.ResourceDescriptor{intoto.Descriptor("provenance.json", []byte("provenance"))},
+		TimeVerified:      time.Date(2023, 7, 1, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60)),
+	}
+	predicate := func(result string, levels ...string) Predicate {
+		if levels == nil {
+			levels = []string{}
+		}
+		return Predicate{
+			Verifier:     Verifier{ID: "https://github.com/org/verifier"},
+			TimeVerified: "2023-07-01T18:00:00Z",
+			ResourceURI:  "docker.io/org/image",
+			Policy: intoto.ResourceDescriptor{
+				URI:    "git+https://github.com/org/repo/policy.json@refs/heads/main",
+				Digest: map[string]string{"sha256": "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"},
+			},
+			InputAttestations: []intoto.ResourceDescriptor{
+				{
+					URI:    "provenance.json",
+					Digest: map[string]string{"sha256": "96d815328a42cb4ef89d5e0b7a1df6be43b484832c83a7b4596d8402c7c0b12b"},
+				},
+			},
+			VerificationResult: result,
+			VerifiedLevels:     levels,
+			SlsaVersion:        "1.0",
+		}
+	}
+
+	tests := []struct {
+		name     string
+		summary  func(Summary) Summary
+		result   results.Result
+		expected *Predicate
+	}{
+		{
+			name:     "pass",
+			result:   results.Result{Decision: "pass", BuildLevel: 3},
+			expected: func() *Predicate { p := predicate(ResultPassed, "SLSA_BUILD_LEVEL_3"); return &p }(),
+		},
+		{
+			name:     "fail",
+			result:   results.Result{Decision: "fail", BuildLevel: 3},
+			expected: func() *Predicate { p := predicate(ResultFailed); return &p }(),
+		},
+		{
+			name:     "audit",
+			result:   results.Result{Decision: "audit"},
+			expected: func() *Predicate { p := predicate(ResultFailed); return &p }(),
+		},
+		{
+			name:   "invalid",
+			result: results.Result{Decision: "invalid"},
+		},
+		{
+			name:    "no subject digest",
+			summary: func(s Summary) Summary { s.Subject.Digest = nil; return s },
+			result:  results.Result{Decision: "pass"},
+		},
+		{
+			name:    "no verifier",
+			summary: func(s Summary) Summary { s.VerifierID = ""; return s },
+			result:  results.Result{Decision: "pass"},
+		},
+		{
+			name: "metadata",
+			summary: func(s Summary) Summary {
+				s.Namespace = "prod"
+				s.Labels = map[string]string{"app.kubernetes.io/name": "echo", "tier": ""}
+				return s
+			},
+			result: results.Result{Decision: "pass", BuildLevel: 3},
+			expected: func() *Predicate {
+				p := predicate(ResultPassed, "SLSA_BUILD_LEVEL_3")
+				p.Metadata = &Metadata{
+					Namespace: "prod",
+					Labels:    map[string]string{"app.kubernetes.io/name": "echo", "tier": ""},
+				}
+				return &p
+			}(),
+		},
+		{
+			name:    "invalid namespace",
+			summary: func(s Summary) Summary { s.Namespace = "Prod"; return s },
+			result:  results.Result{Decision: "pass"},
+		},
+		{
+			name:    "invalid label key",
+			summary: func(s Summary) Summary { s.Labels = map[string]string{"Org.IO/name": "echo"}; return s },
+			result:  results.Result{Decision: "pass"},
+		},
+		{
+			name:    "invalid label value",
+			summary: func(s Summary) Summary { s.Labels = map[string]string{"app": "echo\"}"}; return s },
+			result:  results.Result{Decision: "pass"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := summary
+			if tt.summary != nil {
+				s = tt.summary(s)
+			}
+			statement, err := New(s, tt.result)
+			if diff := cmp.Diff(tt.expected == nil, err != nil); diff != "" {
+				t.Fatalf("unexpected error (-want +got): %v\n%s", err, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(intoto.StatementType, statement.Type); diff != "" {
+				t.Fatalf("unexpected type (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(PredicateType, statement.PredicateType); diff != "" {
+				t.Fatalf("unexpected predicate type (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff([]intoto.ResourceDescriptor{s.Subject}, statement.Subject); diff != "" {
+				t.Fatalf("unexpected subject (-want +got): \n%s", diff)
+			}
+			if diff := cmp.Diff(*tt.expected, statement.Predicate); diff != "" {
+				t.Fatalf("unexpected predicate (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
+
+// Test_New_repoPolicy evaluates the policy of the repository, as the
+// verify workflow does, and creates the VSA of the result.
+func Test_New_repoPolicy(t *testing.T) {
+	t.Parallel()
+
+	repoContent := []byte(`{"version": 1}`)
+	repo := filepath.Join(t.TempDir(), "repo.json")
+	if err := os.WriteFile(repo, repoContent, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	pol, err := policy.FromFiles([]string{"../../.slsa/policy.json", repo})
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		builderID string
+		result    string
+		levels    []string
+	}{
+		{
+			name:      "trusted builder",
+			builderID: "https://github.com/slsa-framework/slsa-github-generator/.github/workflows/generator_container_slsa3.yml",
+			result:    ResultPassed,
+			levels:    []string{"SLSA_BUILD_LEVEL_3"},
+		},
+		{
+			name:      "untrusted builder",
+			builderID: "https://github.com/org/builder",
+			result:    ResultFailed,
+			levels:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt // Re-initializing variable so it is not changed while executing the closure below
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			image := "ghcr.io/laurentsimon/image@sha256:abcd"
+			result := pol.Evaluate("git+https://github.com/laurentsimon/repo@refs/heads/main", image, tt.builderID)
+			statement, err := New(Summary{
+				Subject:      intoto.ResourceDescriptor{Name: "ghcr.io/laurentsimon/image", Digest: map[string]string{"sha256": "abcd"}},
+				ResourceURI:  "ghcr.io/laurentsimon/image",
+				VerifierID:   "https://github.com/org/verifier",
+				Policy:       intoto.Descriptor("git+https://github.com/laurentsimon/repo/repo.json@refs/heads/main", repoContent),
+				TimeVerified: time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC),
+			}, result.Result())
+			if err != nil {
+				t.Fatalf("failed to create VSA: %v", err)
+			}
+			if diff := cmp.Diff(tt.result, statement.Predicate.VerificationResult); diff != "" {
+				t.Fatalf("unexpected result (-want +got): %v\n%s", result, diff)
+			}
+			if diff := cmp.Diff(tt.levels, statement.Predicate.VerifiedLevels); diff != "" {
+				t.Fatalf("unexpected levels (-want +got): \n%s", diff)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@
 
 #set -euo pipefail
 
-go run . eval --labels 'key1=val1, key2=val2' --files 'pkg/policy/testdata/org.json,pkg/policy/testdata/repo.json' --source-uri git+https://github.com/googlenot/repo1 --image-uri docker://googlenot/myimage:v1.2.3 --builder-id https://github.com/another/org/.github/workflows/generator_container_slsa3.ym
+go run . eval --labels 'key1=val1, key2=val2' --files 'pkg/policy/testdata/org.json,pkg/policy/testdata/repo.json' --source-uri git+https://github.com/googlenot/repo1 --image-uri docker://googlenot/myimage:v1.2.3 --builder-id https://github.com/another/org/.github/workflows/generator_container_slsa3.yml --source-attestor-id https://github.com/source-attestor
 echo $?
 
 export GITHUB_REF=refs/tags/v1.2.3